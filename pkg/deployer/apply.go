@@ -0,0 +1,228 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ApplyMode controls how the Deployer reconciles desired state with whatever
+// is already live in the cluster.
+type ApplyMode int
+
+const (
+	// ApplyModeCreateOnly is the historical behavior: every deploy call
+	// issues a Create and fails with AlreadyExists if the object already
+	// exists. This remains the default so existing callers are unaffected.
+	ApplyModeCreateOnly ApplyMode = iota
+	// ApplyModeUpdate creates objects that don't exist yet, and otherwise
+	// computes a three-way merge patch between the last-applied
+	// configuration, the live object, and the desired object, then PATCHes.
+	ApplyModeUpdate
+	// ApplyModeCreateOrPatch is an explicit alias for ApplyModeUpdate, for
+	// callers that want the create-if-absent/patch-if-present semantics to
+	// be unambiguous at the call site.
+	ApplyModeCreateOrPatch = ApplyModeUpdate
+)
+
+// LastAppliedConfigAnnotation stores the JSON of the manifest the Deployer
+// last applied for an object, mirroring the annotation kubectl apply uses.
+// It is the "original" side of the three-way merge on subsequent applies.
+const LastAppliedConfigAnnotation = "horizon.synopsys.com/last-applied-configuration"
+
+// SetApplyMode changes how subsequent calls to Run reconcile objects with
+// the cluster. The default mode is ApplyModeCreateOnly.
+func (d *Deployer) SetApplyMode(mode ApplyMode) {
+	d.applyMode = mode
+}
+
+// applyObject reconciles a single desired object with the cluster according
+// to the Deployer's apply mode. getFn returns the live object (a NotFound
+// error if it doesn't exist yet), createFn creates the desired object, and
+// patchFn applies a computed patch of the given type. desired is mutated in
+// place to carry the last-applied-configuration annotation before it is
+// sent to the cluster, so getFn/createFn/patchFn should be invoked only
+// after calling this function.
+func (d *Deployer) applyObject(
+	kind string,
+	name string,
+	desired runtime.Object,
+	getFn func() (runtime.Object, error),
+	createFn func(runtime.Object) error,
+	patchFn func(k8stypes.PatchType, []byte) error,
+) error {
+	return applyObjectWithMode(d.applyMode, kind, name, desired, getFn, createFn, patchFn)
+}
+
+// applyObjectWithMode is applyObject's mode-parametrized core, split out so
+// KindHandler implementations that aren't backed by a Deployer's configured
+// ApplyMode (e.g. built-in handlers for Kinds registered generically via
+// RegisterKind) can still follow the same create-or-patch,
+// last-applied-configuration convention.
+func applyObjectWithMode(
+	mode ApplyMode,
+	kind string,
+	name string,
+	desired runtime.Object,
+	getFn func() (runtime.Object, error),
+	createFn func(runtime.Object) error,
+	patchFn func(k8stypes.PatchType, []byte) error,
+) error {
+	modified, err := json.Marshal(desired)
+	if err != nil {
+		return fmt.Errorf("unable to marshal desired %s %s: %v", kind, name, err)
+	}
+
+	if mode == ApplyModeCreateOnly {
+		setLastAppliedAnnotation(desired, modified)
+		log.Infof("Creating %s %s", kind, name)
+		return createFn(desired)
+	}
+
+	live, err := getFn()
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("unable to get live %s %s: %v", kind, name, err)
+		}
+		setLastAppliedAnnotation(desired, modified)
+		log.Infof("Creating %s %s", kind, name)
+		return createFn(desired)
+	}
+
+	current, err := json.Marshal(live)
+	if err != nil {
+		return fmt.Errorf("unable to marshal live %s %s: %v", kind, name, err)
+	}
+
+	original := lastAppliedAnnotation(live)
+	setLastAppliedAnnotation(desired, modified)
+	modified, err = json.Marshal(desired)
+	if err != nil {
+		return fmt.Errorf("unable to marshal desired %s %s with annotation: %v", kind, name, err)
+	}
+
+	patch, patchType, err := threeWayPatch(original, modified, current, desired)
+	if err != nil {
+		return fmt.Errorf("unable to compute patch for %s %s: %v", kind, name, err)
+	}
+
+	log.Infof("Patching %s %s", kind, name)
+	return patchFn(patchType, patch)
+}
+
+// threeWayPatch computes the patch to send to the API server in order to go
+// from the live state (current) to the desired state (modified), taking
+// into account what was applied last time (original). strategicpatch.
+// NewPatchMetaFromStruct succeeds for any real Go struct, including the
+// compiled-in types every built-in KindHandler in this package constructs
+// (CRDs included), so those all take CreateThreeWayMergePatch; the JSON
+// merge patch fallback only matters for objects it can't derive patch
+// metadata from, e.g. a future KindHandler backed by a dynamic client and
+// unstructured.Unstructured rather than a typed struct.
+func threeWayPatch(original, modified, current []byte, obj runtime.Object) ([]byte, k8stypes.PatchType, error) {
+	patchMeta, err := strategicpatch.NewPatchMetaFromStruct(obj)
+	if err == nil {
+		patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, patchMeta, true)
+		if err == nil {
+			return patch, k8stypes.StrategicMergePatchType, nil
+		}
+	}
+
+	patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current)
+	if err != nil {
+		return nil, "", err
+	}
+	return patch, k8stypes.MergePatchType, nil
+}
+
+// setLastAppliedAnnotation stamps obj with the JSON it is about to be
+// created or patched with, so the next apply can compute a proper
+// three-way merge against it.
+func setLastAppliedAnnotation(obj runtime.Object, raw []byte) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	annotations := accessor.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[LastAppliedConfigAnnotation] = string(raw)
+	accessor.SetAnnotations(annotations)
+}
+
+// lastAppliedAnnotation returns the last-applied-configuration recorded on
+// the live object, or an empty JSON object if it was never set (e.g. the
+// object was created outside of the Deployer).
+func lastAppliedAnnotation(obj runtime.Object) []byte {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return []byte("{}")
+	}
+	if raw, ok := accessor.GetAnnotations()[LastAppliedConfigAnnotation]; ok {
+		return []byte(raw)
+	}
+	return []byte("{}")
+}
+
+// deleteManagedObject removes a previously deployed object, following the
+// same last-applied-configuration annotation used by applyObject so that
+// Undo only ever touches objects the Deployer itself created. It isn't a
+// method on Deployer so KindHandler implementations that aren't backed by
+// one (e.g. built-in handlers for Kinds registered generically via
+// RegisterKind) can still follow the same convention.
+func deleteManagedObject(
+	kind string,
+	name string,
+	getFn func() (runtime.Object, error),
+	deleteFn func() error,
+) error {
+	live, err := getFn()
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to get live %s %s: %v", kind, name, err)
+	}
+
+	accessor, err := meta.Accessor(live)
+	if err != nil {
+		return fmt.Errorf("unable to access %s %s: %v", kind, name, err)
+	}
+	if _, ok := accessor.GetAnnotations()[LastAppliedConfigAnnotation]; !ok {
+		log.Warnf("Skipping %s %s: not managed by this Deployer (missing %s annotation)", kind, name, LastAppliedConfigAnnotation)
+		return nil
+	}
+
+	log.Infof("Deleting %s %s", kind, name)
+	return deleteFn()
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// fakeStructObject stands in for a compiled-in Kube API type: a plain
+// struct, so strategicpatch.NewPatchMetaFromStruct can walk its fields.
+type fakeStructObject struct {
+	metav1.TypeMeta `json:",inline"`
+	Value           string `json:"value,omitempty"`
+}
+
+func (f *fakeStructObject) DeepCopyObject() runtime.Object {
+	cp := *f
+	return &cp
+}
+
+// fakeMapObject stands in for a CRD or other resource with no registered
+// patch metadata: a map-backed runtime.Object, the shape
+// strategicpatch.NewPatchMetaFromStruct rejects since it isn't a struct.
+type fakeMapObject map[string]interface{}
+
+func (f fakeMapObject) GetObjectKind() schema.ObjectKind { return schema.EmptyObjectKind }
+func (f fakeMapObject) DeepCopyObject() runtime.Object {
+	cp := make(fakeMapObject, len(f))
+	for k, v := range f {
+		cp[k] = v
+	}
+	return cp
+}
+
+func TestThreeWayPatch_StructuredObjectUsesStrategicMerge(t *testing.T) {
+	original := []byte(`{"value":"a"}`)
+	current := []byte(`{"value":"a"}`)
+	modified := []byte(`{"value":"b"}`)
+
+	patch, patchType, err := threeWayPatch(original, modified, current, &fakeStructObject{Value: "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if patchType != k8stypes.StrategicMergePatchType {
+		t.Errorf("patchType = %v, want %v", patchType, k8stypes.StrategicMergePatchType)
+	}
+	if len(patch) == 0 {
+		t.Errorf("expected a non-empty patch for a changed field")
+	}
+}
+
+func TestThreeWayPatch_UnstructuredObjectFallsBackToJSONMerge(t *testing.T) {
+	original := []byte(`{"value":"a"}`)
+	current := []byte(`{"value":"a"}`)
+	modified := []byte(`{"value":"b"}`)
+
+	patch, patchType, err := threeWayPatch(original, modified, current, fakeMapObject{"value": "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if patchType != k8stypes.MergePatchType {
+		t.Errorf("patchType = %v, want %v (strategic merge metadata doesn't exist for a non-struct object)", patchType, k8stypes.MergePatchType)
+	}
+	if len(patch) == 0 {
+		t.Errorf("expected a non-empty patch for a changed field")
+	}
+}
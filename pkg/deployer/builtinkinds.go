@@ -0,0 +1,586 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/types"
+	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
+
+	"github.com/koki/short/converter/converters"
+	shorttypes "github.com/koki/short/types"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// GroupVersionKinds for the built-in Kinds this Deployer ships handlers
+// for beyond the original ten, so RegisterKind callers and LoadManifests
+// can refer to them without redeclaring the same values.
+var (
+	JobGVK                     = schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}
+	CronJobGVK                 = schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"}
+	StatefulSetGVK             = schema.GroupVersionKind{Group: "apps", Version: "v1beta2", Kind: "StatefulSet"}
+	DaemonSetGVK               = schema.GroupVersionKind{Group: "apps", Version: "v1beta2", Kind: "DaemonSet"}
+	IngressGVK                 = schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}
+	NetworkPolicyGVK           = schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "NetworkPolicy"}
+	HorizontalPodAutoscalerGVK = schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "HorizontalPodAutoscaler"}
+	PodDisruptionBudgetGVK     = schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"}
+)
+
+// registerBuiltinKindHandlers wires up the Deployer's out-of-the-box
+// KindHandlers, covering both the original ten Kinds plus Pod (see
+// registerLegacyKindHandlers), and the eight below, whose typed Add*
+// methods (AddJob, AddCronJob, ...) go through AddCustomResource. Downstream
+// projects can override any of these, or add entirely new Kinds (including
+// CRD-typed ones backed by a dynamic client), via RegisterKind.
+func registerBuiltinKindHandlers(d *Deployer) {
+	registerLegacyKindHandlers(d)
+
+	d.RegisterKind(JobGVK, jobHandler{})
+	d.RegisterKind(CronJobGVK, cronJobHandler{})
+	d.RegisterKind(StatefulSetGVK, statefulSetHandler{})
+	d.RegisterKind(DaemonSetGVK, daemonSetHandler{})
+	d.RegisterKind(IngressGVK, ingressHandler{})
+	d.RegisterKind(NetworkPolicyGVK, networkPolicyHandler{})
+	d.RegisterKind(HorizontalPodAutoscalerGVK, horizontalPodAutoscalerHandler{})
+	d.RegisterKind(PodDisruptionBudgetGVK, podDisruptionBudgetHandler{})
+}
+
+// AddJob registers a Job to be deployed under the Workload tier, the same
+// tier as Deployments and ReplicationControllers. Run waits for it to
+// report at least one Succeeded pod before considering it ready.
+func (d *Deployer) AddJob(obj *types.Job, opts ...AddOption) error {
+	return d.AddCustomResource(JobGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+// AddCronJob registers a CronJob to be deployed under the Workload tier.
+func (d *Deployer) AddCronJob(obj *types.CronJob, opts ...AddOption) error {
+	return d.AddCustomResource(CronJobGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+// AddStatefulSet registers a StatefulSet to be deployed under the Workload
+// tier. Run waits for its ReadyReplicas to catch up with its desired
+// Replicas before considering it ready.
+func (d *Deployer) AddStatefulSet(obj *types.StatefulSet, opts ...AddOption) error {
+	return d.AddCustomResource(StatefulSetGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+// AddDaemonSet registers a DaemonSet to be deployed under the Workload
+// tier. Run waits for NumberReady to catch up with DesiredNumberScheduled
+// before considering it ready.
+func (d *Deployer) AddDaemonSet(obj *types.DaemonSet, opts ...AddOption) error {
+	return d.AddCustomResource(DaemonSetGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+// AddIngress registers an Ingress to be deployed under the Service tier,
+// alongside Services, since it typically fronts one.
+func (d *Deployer) AddIngress(obj *types.Ingress, opts ...AddOption) error {
+	return d.AddCustomResource(IngressGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+// AddNetworkPolicy registers a NetworkPolicy to be deployed under the same
+// tier as ConfigMaps and Secrets, since it has no workload of its own to
+// wait on.
+func (d *Deployer) AddNetworkPolicy(obj *types.NetworkPolicy, opts ...AddOption) error {
+	return d.AddCustomResource(NetworkPolicyGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+// AddHorizontalPodAutoscaler registers an HPA to be deployed under the
+// Service tier, since it targets a Deployment or StatefulSet that must
+// already exist.
+func (d *Deployer) AddHorizontalPodAutoscaler(obj *types.HorizontalPodAutoscaler, opts ...AddOption) error {
+	return d.AddCustomResource(HorizontalPodAutoscalerGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+// AddPodDisruptionBudget registers a PodDisruptionBudget to be deployed
+// under the Service tier, for the same reason as HorizontalPodAutoscaler.
+func (d *Deployer) AddPodDisruptionBudget(obj *types.PodDisruptionBudget, opts ...AddOption) error {
+	return d.AddCustomResource(PodDisruptionBudgetGVK, obj.GetName(), obj.GetObj(), opts...)
+}
+
+type jobHandler struct{}
+
+func (jobHandler) ComponentType() utils.ComponentType { return utils.JobComponent }
+func (jobHandler) Tier() int                          { return int(tierWorkload) }
+
+func (jobHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.Job)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.Job, got %T", short)
+	}
+	return converters.Convert_Koki_Job_to_Kube_batch_v1_Job(&shorttypes.JobWrapper{Job: *obj})
+}
+
+func (jobHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	job := obj.(*batchv1.Job)
+	_, err := cluster.Client.BatchV1().Jobs(job.Namespace).Create(job)
+	return err
+}
+
+func (jobHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	job := obj.(*batchv1.Job)
+	jobs := cluster.Client.BatchV1().Jobs(job.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "job", job.Name, job,
+		func() (runtime.Object, error) { return jobs.Get(job.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := jobs.Create(o.(*batchv1.Job)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := jobs.Patch(job.Name, pt, patch); return err },
+	)
+}
+
+func (jobHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	job := obj.(*batchv1.Job)
+	jobs := cluster.Client.BatchV1().Jobs(job.Namespace)
+	return deleteManagedObject("job", job.Name,
+		func() (runtime.Object, error) { return jobs.Get(job.Name, metav1.GetOptions{}) },
+		func() error { return jobs.Delete(job.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+func (jobHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	job := obj.(*batchv1.Job)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := cluster.Client.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return live.Status.Succeeded > 0, nil
+	})
+}
+
+func (h jobHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	job := obj.(*batchv1.Job)
+	jobs := cluster.Client.BatchV1().Jobs(job.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.BatchV1().RESTClient(), resource: "jobs", namespace: job.Namespace}
+	diff, err := diffObjectWithMode(mode, "job", h.ComponentType(), job.Namespace, job.Name, job,
+		func() (runtime.Object, error) { return jobs.Get(job.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type cronJobHandler struct{}
+
+func (cronJobHandler) ComponentType() utils.ComponentType { return utils.CronJobComponent }
+func (cronJobHandler) Tier() int                          { return int(tierWorkload) }
+
+func (cronJobHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.CronJob)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.CronJob, got %T", short)
+	}
+	return converters.Convert_Koki_CronJob_to_Kube_batch_v1beta1_CronJob(&shorttypes.CronJobWrapper{CronJob: *obj})
+}
+
+func (cronJobHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	cj := obj.(*batchv1beta1.CronJob)
+	_, err := cluster.Client.BatchV1beta1().CronJobs(cj.Namespace).Create(cj)
+	return err
+}
+
+func (cronJobHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	cj := obj.(*batchv1beta1.CronJob)
+	cronJobs := cluster.Client.BatchV1beta1().CronJobs(cj.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "cron job", cj.Name, cj,
+		func() (runtime.Object, error) { return cronJobs.Get(cj.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := cronJobs.Create(o.(*batchv1beta1.CronJob)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := cronJobs.Patch(cj.Name, pt, patch); return err },
+	)
+}
+
+func (cronJobHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	cj := obj.(*batchv1beta1.CronJob)
+	cronJobs := cluster.Client.BatchV1beta1().CronJobs(cj.Namespace)
+	return deleteManagedObject("cron job", cj.Name,
+		func() (runtime.Object, error) { return cronJobs.Get(cj.Name, metav1.GetOptions{}) },
+		func() error { return cronJobs.Delete(cj.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op: a CronJob is ready as soon as it's created, since
+// there's no workload to wait on until it next fires.
+func (cronJobHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h cronJobHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	cj := obj.(*batchv1beta1.CronJob)
+	cronJobs := cluster.Client.BatchV1beta1().CronJobs(cj.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.BatchV1beta1().RESTClient(), resource: "cronjobs", namespace: cj.Namespace}
+	diff, err := diffObjectWithMode(mode, "cron job", h.ComponentType(), cj.Namespace, cj.Name, cj,
+		func() (runtime.Object, error) { return cronJobs.Get(cj.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type statefulSetHandler struct{}
+
+func (statefulSetHandler) ComponentType() utils.ComponentType { return utils.StatefulSetComponent }
+func (statefulSetHandler) Tier() int                          { return int(tierWorkload) }
+
+func (statefulSetHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.StatefulSet)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.StatefulSet, got %T", short)
+	}
+	return converters.Convert_Koki_StatefulSet_to_Kube_apps_v1beta2_StatefulSet(&shorttypes.StatefulSetWrapper{StatefulSet: *obj})
+}
+
+func (statefulSetHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	ss := obj.(*appsv1beta2.StatefulSet)
+	_, err := cluster.Client.AppsV1beta2().StatefulSets(ss.Namespace).Create(ss)
+	return err
+}
+
+func (statefulSetHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	ss := obj.(*appsv1beta2.StatefulSet)
+	statefulSets := cluster.Client.AppsV1beta2().StatefulSets(ss.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "stateful set", ss.Name, ss,
+		func() (runtime.Object, error) { return statefulSets.Get(ss.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := statefulSets.Create(o.(*appsv1beta2.StatefulSet)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := statefulSets.Patch(ss.Name, pt, patch); return err },
+	)
+}
+
+func (statefulSetHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	ss := obj.(*appsv1beta2.StatefulSet)
+	statefulSets := cluster.Client.AppsV1beta2().StatefulSets(ss.Namespace)
+	return deleteManagedObject("stateful set", ss.Name,
+		func() (runtime.Object, error) { return statefulSets.Get(ss.Name, metav1.GetOptions{}) },
+		func() error { return statefulSets.Delete(ss.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+func (statefulSetHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	ss := obj.(*appsv1beta2.StatefulSet)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := cluster.Client.AppsV1beta2().StatefulSets(ss.Namespace).Get(ss.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		desired := int32(1)
+		if live.Spec.Replicas != nil {
+			desired = *live.Spec.Replicas
+		}
+		return live.Status.ReadyReplicas >= desired, nil
+	})
+}
+
+func (h statefulSetHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	ss := obj.(*appsv1beta2.StatefulSet)
+	statefulSets := cluster.Client.AppsV1beta2().StatefulSets(ss.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.AppsV1beta2().RESTClient(), resource: "statefulsets", namespace: ss.Namespace}
+	diff, err := diffObjectWithMode(mode, "stateful set", h.ComponentType(), ss.Namespace, ss.Name, ss,
+		func() (runtime.Object, error) { return statefulSets.Get(ss.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type daemonSetHandler struct{}
+
+func (daemonSetHandler) ComponentType() utils.ComponentType { return utils.DaemonSetComponent }
+func (daemonSetHandler) Tier() int                          { return int(tierWorkload) }
+
+func (daemonSetHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.DaemonSet)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.DaemonSet, got %T", short)
+	}
+	return converters.Convert_Koki_DaemonSet_to_Kube_apps_v1beta2_DaemonSet(&shorttypes.DaemonSetWrapper{DaemonSet: *obj})
+}
+
+func (daemonSetHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	ds := obj.(*appsv1beta2.DaemonSet)
+	_, err := cluster.Client.AppsV1beta2().DaemonSets(ds.Namespace).Create(ds)
+	return err
+}
+
+func (daemonSetHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	ds := obj.(*appsv1beta2.DaemonSet)
+	daemonSets := cluster.Client.AppsV1beta2().DaemonSets(ds.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "daemon set", ds.Name, ds,
+		func() (runtime.Object, error) { return daemonSets.Get(ds.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := daemonSets.Create(o.(*appsv1beta2.DaemonSet)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := daemonSets.Patch(ds.Name, pt, patch); return err },
+	)
+}
+
+func (daemonSetHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	ds := obj.(*appsv1beta2.DaemonSet)
+	daemonSets := cluster.Client.AppsV1beta2().DaemonSets(ds.Namespace)
+	return deleteManagedObject("daemon set", ds.Name,
+		func() (runtime.Object, error) { return daemonSets.Get(ds.Name, metav1.GetOptions{}) },
+		func() error { return daemonSets.Delete(ds.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+func (daemonSetHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	ds := obj.(*appsv1beta2.DaemonSet)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := cluster.Client.AppsV1beta2().DaemonSets(ds.Namespace).Get(ds.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return live.Status.NumberReady >= live.Status.DesiredNumberScheduled, nil
+	})
+}
+
+func (h daemonSetHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	ds := obj.(*appsv1beta2.DaemonSet)
+	daemonSets := cluster.Client.AppsV1beta2().DaemonSets(ds.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.AppsV1beta2().RESTClient(), resource: "daemonsets", namespace: ds.Namespace}
+	diff, err := diffObjectWithMode(mode, "daemon set", h.ComponentType(), ds.Namespace, ds.Name, ds,
+		func() (runtime.Object, error) { return daemonSets.Get(ds.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type ingressHandler struct{}
+
+func (ingressHandler) ComponentType() utils.ComponentType { return utils.IngressComponent }
+func (ingressHandler) Tier() int                          { return int(tierService) }
+
+func (ingressHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.Ingress, got %T", short)
+	}
+	return converters.Convert_Koki_Ingress_to_Kube_extensions_v1beta1_Ingress(&shorttypes.IngressWrapper{Ingress: *obj})
+}
+
+func (ingressHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	ing := obj.(*extensionsv1beta1.Ingress)
+	_, err := cluster.Client.ExtensionsV1beta1().Ingresses(ing.Namespace).Create(ing)
+	return err
+}
+
+func (ingressHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	ing := obj.(*extensionsv1beta1.Ingress)
+	ingresses := cluster.Client.ExtensionsV1beta1().Ingresses(ing.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "ingress", ing.Name, ing,
+		func() (runtime.Object, error) { return ingresses.Get(ing.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := ingresses.Create(o.(*extensionsv1beta1.Ingress)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := ingresses.Patch(ing.Name, pt, patch); return err },
+	)
+}
+
+func (ingressHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	ing := obj.(*extensionsv1beta1.Ingress)
+	ingresses := cluster.Client.ExtensionsV1beta1().Ingresses(ing.Namespace)
+	return deleteManagedObject("ingress", ing.Name,
+		func() (runtime.Object, error) { return ingresses.Get(ing.Name, metav1.GetOptions{}) },
+		func() error { return ingresses.Delete(ing.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+func (ingressHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	ing := obj.(*extensionsv1beta1.Ingress)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := cluster.Client.ExtensionsV1beta1().Ingresses(ing.Namespace).Get(ing.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return len(live.Status.LoadBalancer.Ingress) > 0, nil
+	})
+}
+
+func (h ingressHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	ing := obj.(*extensionsv1beta1.Ingress)
+	ingresses := cluster.Client.ExtensionsV1beta1().Ingresses(ing.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.ExtensionsV1beta1().RESTClient(), resource: "ingresses", namespace: ing.Namespace}
+	diff, err := diffObjectWithMode(mode, "ingress", h.ComponentType(), ing.Namespace, ing.Name, ing,
+		func() (runtime.Object, error) { return ingresses.Get(ing.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type networkPolicyHandler struct{}
+
+func (networkPolicyHandler) ComponentType() utils.ComponentType { return utils.NetworkPolicyComponent }
+func (networkPolicyHandler) Tier() int                          { return int(tierNamedConfig) }
+
+func (networkPolicyHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.NetworkPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.NetworkPolicy, got %T", short)
+	}
+	return converters.Convert_Koki_NetworkPolicy_to_Kube_extensions_v1beta1_NetworkPolicy(&shorttypes.NetworkPolicyWrapper{NetworkPolicy: *obj})
+}
+
+func (networkPolicyHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	np := obj.(*extensionsv1beta1.NetworkPolicy)
+	_, err := cluster.Client.ExtensionsV1beta1().NetworkPolicies(np.Namespace).Create(np)
+	return err
+}
+
+func (networkPolicyHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	np := obj.(*extensionsv1beta1.NetworkPolicy)
+	policies := cluster.Client.ExtensionsV1beta1().NetworkPolicies(np.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "network policy", np.Name, np,
+		func() (runtime.Object, error) { return policies.Get(np.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := policies.Create(o.(*extensionsv1beta1.NetworkPolicy)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := policies.Patch(np.Name, pt, patch); return err },
+	)
+}
+
+func (networkPolicyHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	np := obj.(*extensionsv1beta1.NetworkPolicy)
+	policies := cluster.Client.ExtensionsV1beta1().NetworkPolicies(np.Namespace)
+	return deleteManagedObject("network policy", np.Name,
+		func() (runtime.Object, error) { return policies.Get(np.Name, metav1.GetOptions{}) },
+		func() error { return policies.Delete(np.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op: a NetworkPolicy takes effect as soon as it's
+// created, with no workload of its own to wait on.
+func (networkPolicyHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h networkPolicyHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	np := obj.(*extensionsv1beta1.NetworkPolicy)
+	policies := cluster.Client.ExtensionsV1beta1().NetworkPolicies(np.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.ExtensionsV1beta1().RESTClient(), resource: "networkpolicies", namespace: np.Namespace}
+	diff, err := diffObjectWithMode(mode, "network policy", h.ComponentType(), np.Namespace, np.Name, np,
+		func() (runtime.Object, error) { return policies.Get(np.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type horizontalPodAutoscalerHandler struct{}
+
+func (horizontalPodAutoscalerHandler) ComponentType() utils.ComponentType {
+	return utils.HorizontalPodAutoscalerComponent
+}
+func (horizontalPodAutoscalerHandler) Tier() int { return int(tierService) }
+
+func (horizontalPodAutoscalerHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.HorizontalPodAutoscaler)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.HorizontalPodAutoscaler, got %T", short)
+	}
+	return converters.Convert_Koki_HorizontalPodAutoscaler_to_Kube_autoscaling_v1_HorizontalPodAutoscaler(
+		&shorttypes.HorizontalPodAutoscalerWrapper{HorizontalPodAutoscaler: *obj})
+}
+
+func (horizontalPodAutoscalerHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	hpa := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	_, err := cluster.Client.AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace).Create(hpa)
+	return err
+}
+
+func (horizontalPodAutoscalerHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	hpa := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	hpas := cluster.Client.AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "horizontal pod autoscaler", hpa.Name, hpa,
+		func() (runtime.Object, error) { return hpas.Get(hpa.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := hpas.Create(o.(*autoscalingv1.HorizontalPodAutoscaler)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := hpas.Patch(hpa.Name, pt, patch); return err },
+	)
+}
+
+func (horizontalPodAutoscalerHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	hpa := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	hpas := cluster.Client.AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace)
+	return deleteManagedObject("horizontal pod autoscaler", hpa.Name,
+		func() (runtime.Object, error) { return hpas.Get(hpa.Name, metav1.GetOptions{}) },
+		func() error { return hpas.Delete(hpa.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady only confirms the HPA is visible to the API server: its
+// CurrentReplicas/CurrentCPUUtilization aren't populated until the next
+// metrics sync, so there's nothing meaningful to poll for at deploy time.
+func (horizontalPodAutoscalerHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	hpa := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	_, err := cluster.Client.AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace).Get(hpa.Name, metav1.GetOptions{})
+	return err
+}
+
+func (h horizontalPodAutoscalerHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	hpa := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	hpas := cluster.Client.AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.AutoscalingV1().RESTClient(), resource: "horizontalpodautoscalers", namespace: hpa.Namespace}
+	diff, err := diffObjectWithMode(mode, "horizontal pod autoscaler", h.ComponentType(), hpa.Namespace, hpa.Name, hpa,
+		func() (runtime.Object, error) { return hpas.Get(hpa.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type podDisruptionBudgetHandler struct{}
+
+func (podDisruptionBudgetHandler) ComponentType() utils.ComponentType {
+	return utils.PodDisruptionBudgetComponent
+}
+func (podDisruptionBudgetHandler) Tier() int { return int(tierService) }
+
+func (podDisruptionBudgetHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.PodDisruptionBudget)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.PodDisruptionBudget, got %T", short)
+	}
+	return converters.Convert_Koki_PodDisruptionBudget_to_Kube_policy_v1beta1_PodDisruptionBudget(
+		&shorttypes.PodDisruptionBudgetWrapper{PodDisruptionBudget: *obj})
+}
+
+func (podDisruptionBudgetHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	pdb := obj.(*policyv1beta1.PodDisruptionBudget)
+	_, err := cluster.Client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace).Create(pdb)
+	return err
+}
+
+func (podDisruptionBudgetHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	pdb := obj.(*policyv1beta1.PodDisruptionBudget)
+	pdbs := cluster.Client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "pod disruption budget", pdb.Name, pdb,
+		func() (runtime.Object, error) { return pdbs.Get(pdb.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := pdbs.Create(o.(*policyv1beta1.PodDisruptionBudget)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := pdbs.Patch(pdb.Name, pt, patch); return err },
+	)
+}
+
+func (podDisruptionBudgetHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	pdb := obj.(*policyv1beta1.PodDisruptionBudget)
+	pdbs := cluster.Client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace)
+	return deleteManagedObject("pod disruption budget", pdb.Name,
+		func() (runtime.Object, error) { return pdbs.Get(pdb.Name, metav1.GetOptions{}) },
+		func() error { return pdbs.Delete(pdb.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+func (podDisruptionBudgetHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	pdb := obj.(*policyv1beta1.PodDisruptionBudget)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := cluster.Client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace).Get(pdb.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return live.Status.CurrentHealthy >= live.Status.DesiredHealthy, nil
+	})
+}
+
+func (h podDisruptionBudgetHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	pdb := obj.(*policyv1beta1.PodDisruptionBudget)
+	pdbs := cluster.Client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.PolicyV1beta1().RESTClient(), resource: "poddisruptionbudgets", namespace: pdb.Namespace}
+	diff, err := diffObjectWithMode(mode, "pod disruption budget", h.ComponentType(), pdb.Namespace, pdb.Name, pdb,
+		func() (runtime.Object, error) { return pdbs.Get(pdb.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
@@ -0,0 +1,317 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
+	utilserror "github.com/blackducksoftware/cn-crd-controller/pkg/utils/error"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+
+	extensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ClusterSecretLabel marks a Secret in the registry's watched namespace as
+// carrying a kubeconfig for a remote cluster that the Deployer should fan
+// out to, following the same secret-driven cluster registry pattern Istio
+// Admiral uses.
+const ClusterSecretLabel = "horizon.synopsys.com/cluster"
+
+// ClusterSecretKubeconfigKey is the Secret data key holding the kubeconfig
+// bytes for the remote cluster.
+const ClusterSecretKubeconfigKey = "kubeconfig"
+
+// localClusterName identifies the Deployer's own client/apiextensions in
+// error reporting, since it isn't backed by a registry Secret.
+const localClusterName = "<local>"
+
+// maxConcurrentClusterDeploys bounds how many clusters Run deploys to at
+// once when a ClusterRegistry is attached.
+const maxConcurrentClusterDeploys = 4
+
+// Cluster is a single remote cluster discovered through a ClusterRegistry,
+// along with the clientsets built from its kubeconfig.
+type Cluster struct {
+	Name          string
+	Client        *kubernetes.Clientset
+	APIExtensions *extensionsclient.Clientset
+}
+
+// ClusterFilter decides whether a given component type should be deployed
+// to a given cluster, e.g. to restrict CRDs to a control plane cluster.
+// A nil filter deploys every component type to every cluster.
+type ClusterFilter func(cluster *Cluster, componentType utils.ComponentType) bool
+
+// ClusterRegistry watches Secrets labeled with ClusterSecretLabel in a
+// namespace and maintains a live set of remote clusters for a Deployer to
+// fan deployments out to.
+type ClusterRegistry struct {
+	client    *kubernetes.Clientset
+	namespace string
+
+	mu       sync.RWMutex
+	clusters map[string]*Cluster
+
+	onAdd    func(*Cluster)
+	onDelete func(*Cluster)
+}
+
+// NewClusterRegistry creates a ClusterRegistry that watches namespace for
+// kubeconfig Secrets using client.
+func NewClusterRegistry(client *kubernetes.Clientset, namespace string) *ClusterRegistry {
+	return &ClusterRegistry{
+		client:    client,
+		namespace: namespace,
+		clusters:  make(map[string]*Cluster),
+	}
+}
+
+// OnAddCluster registers a callback invoked whenever a cluster secret is
+// added or updated, so a caller can immediately (re)deploy to it.
+func (r *ClusterRegistry) OnAddCluster(f func(*Cluster)) {
+	r.onAdd = f
+}
+
+// OnDeleteCluster registers a callback invoked whenever a cluster secret is
+// removed, so a caller can tear down what it deployed there.
+func (r *ClusterRegistry) OnDeleteCluster(f func(*Cluster)) {
+	r.onDelete = f
+}
+
+// Clusters returns a snapshot of the currently known clusters.
+func (r *ClusterRegistry) Clusters() []*Cluster {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clusters := make([]*Cluster, 0, len(r.clusters))
+	for _, c := range r.clusters {
+		clusters = append(clusters, c)
+	}
+	return clusters
+}
+
+// Run starts watching for cluster secrets and blocks until stopCh is
+// closed.
+func (r *ClusterRegistry) Run(stopCh <-chan struct{}) {
+	selector := fmt.Sprintf("%s=true", ClusterSecretLabel)
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			return r.client.Core().Secrets(r.namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return r.client.Core().Secrets(r.namespace).Watch(options)
+		},
+	}
+
+	_, controller := cache.NewInformer(listWatch, &v1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { r.handleUpsert(obj.(*v1.Secret)) },
+		UpdateFunc: func(old, new interface{}) { r.handleUpsert(new.(*v1.Secret)) },
+		DeleteFunc: func(obj interface{}) {
+			secret, ok := obj.(*v1.Secret)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					return
+				}
+				secret, ok = tombstone.Obj.(*v1.Secret)
+				if !ok {
+					return
+				}
+			}
+			r.handleDelete(secret)
+		},
+	})
+
+	controller.Run(stopCh)
+}
+
+func (r *ClusterRegistry) handleUpsert(secret *v1.Secret) {
+	kubeconfig, ok := secret.Data[ClusterSecretKubeconfigKey]
+	if !ok {
+		log.Warnf("Cluster secret %s/%s is missing the %q key", secret.Namespace, secret.Name, ClusterSecretKubeconfigKey)
+		return
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		log.Errorf("Unable to parse kubeconfig from secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		return
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		log.Errorf("Unable to build client for cluster %s: %v", secret.Name, err)
+		return
+	}
+
+	apiextensions, err := extensionsclient.NewForConfig(restConfig)
+	if err != nil {
+		log.Errorf("Unable to build apiextensions client for cluster %s: %v", secret.Name, err)
+		return
+	}
+
+	cluster := &Cluster{
+		Name:          secret.Name,
+		Client:        client,
+		APIExtensions: apiextensions,
+	}
+
+	r.mu.Lock()
+	r.clusters[secret.Name] = cluster
+	r.mu.Unlock()
+
+	log.Infof("Registered cluster %s", secret.Name)
+	if r.onAdd != nil {
+		r.onAdd(cluster)
+	}
+}
+
+func (r *ClusterRegistry) handleDelete(secret *v1.Secret) {
+	r.mu.Lock()
+	cluster, ok := r.clusters[secret.Name]
+	delete(r.clusters, secret.Name)
+	r.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	log.Infof("Unregistered cluster %s", secret.Name)
+	if r.onDelete != nil {
+		r.onDelete(cluster)
+	}
+}
+
+// ClusterDeployErrors aggregates per-cluster deployment failures, keyed by
+// cluster name, returned by Run when a ClusterRegistry is attached and at
+// least one cluster failed.
+type ClusterDeployErrors map[string]error
+
+// Error implements the error interface.
+func (e ClusterDeployErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for name, err := range e {
+		msgs = append(msgs, fmt.Sprintf("cluster %s: %v", name, err))
+	}
+	sort.Strings(msgs)
+	return strings.Join(msgs, "; ")
+}
+
+// SetClusterRegistry attaches a ClusterRegistry so Run fans the configured
+// components out to every cluster currently known to the registry, in
+// addition to the Deployer's own client/apiextensions. It also registers
+// the registry's OnAddCluster/OnDeleteCluster callbacks so a cluster secret
+// added, updated or removed while registry.Run is watching immediately
+// (re)deploys or tears down the same components on that cluster, without
+// waiting for the next explicit Run call.
+func (d *Deployer) SetClusterRegistry(registry *ClusterRegistry) {
+	d.registry = registry
+
+	registry.OnAddCluster(func(cluster *Cluster) {
+		go func() {
+			if errMap := d.runForCluster(cluster); len(errMap) > 0 {
+				log.Errorf("Deploying to cluster %s failed: %v", cluster.Name, utilserror.NewDeployErrors(errMap))
+			}
+		}()
+	})
+	registry.OnDeleteCluster(func(cluster *Cluster) {
+		go func() {
+			if err := d.undoForCluster(cluster); err != nil {
+				log.Errorf("Tearing down cluster %s failed: %v", cluster.Name, err)
+			}
+		}()
+	})
+}
+
+// SetClusterFilter restricts which component types are deployed to which
+// clusters when a ClusterRegistry is attached (e.g. CRDs only to a control
+// plane cluster). The default, a nil filter, deploys everything everywhere.
+func (d *Deployer) SetClusterFilter(filter ClusterFilter) {
+	d.clusterFilter = filter
+}
+
+// allows reports whether componentType should be deployed to cluster,
+// consulting the Deployer's ClusterFilter if one is set.
+func (d *Deployer) allows(cluster *Cluster, componentType utils.ComponentType) bool {
+	if d.clusterFilter == nil {
+		return true
+	}
+	return d.clusterFilter(cluster, componentType)
+}
+
+// cloneForCluster returns a Deployer carrying the same registered objects
+// and handlers as d, but pointed at cluster's clientsets instead of d's own,
+// so runForCluster and undoForCluster can reuse the same deploy/delete code
+// paths against a remote cluster.
+func (d *Deployer) cloneForCluster(cluster *Cluster) *Deployer {
+	return &Deployer{
+		replicationControllers: d.replicationControllers,
+		pods:                   d.pods,
+		configMaps:             d.configMaps,
+		secrets:                d.secrets,
+		services:               d.services,
+		serviceAccounts:        d.serviceAccounts,
+		deployments:            d.deployments,
+		clusterRoles:           d.clusterRoles,
+		clusterRoleBindings:    d.clusterRoleBindings,
+		crds:                   d.crds,
+		namespaces:             d.namespaces,
+		controllers:            d.controllers,
+		client:                 cluster.Client,
+		apiextensions:          cluster.APIExtensions,
+		applyMode:              d.applyMode,
+		kindHandlers:           d.kindHandlers,
+		customObjects:          d.customObjects,
+	}
+}
+
+// runForCluster runs the dependency-graph deploy sequence (see runDAG)
+// against a single cluster's clientsets, honoring the Deployer's
+// ClusterFilter, and returns any errors keyed by component type.
+func (d *Deployer) runForCluster(cluster *Cluster) map[utils.ComponentType][]error {
+	cd := d.cloneForCluster(cluster)
+	return d.runDAG(cd, func(componentType utils.ComponentType) bool { return d.allows(cluster, componentType) })
+}
+
+// undoForCluster removes every component previously applied by this
+// Deployer from a single cluster, the cluster-scoped mirror of Undo, so a
+// ClusterRegistry can tear down what it deployed to a remote cluster when
+// that cluster's secret is removed.
+func (d *Deployer) undoForCluster(cluster *Cluster) error {
+	return d.cloneForCluster(cluster).Undo()
+}
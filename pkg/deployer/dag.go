@@ -0,0 +1,258 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxConcurrentDAGWorkers bounds how many nodes of the dependency graph Run
+// deploys at once, independent of how many clusters are being fanned out to
+// concurrently.
+const maxConcurrentDAGWorkers = 4
+
+// defaultWaitReadyTimeout bounds how long a single node's WaitReady step may
+// block before the node (and anything depending on it) is considered failed.
+const defaultWaitReadyTimeout = 2 * time.Minute
+
+// dagTier orders the built-in kinds the way the Deployer has always deployed
+// them: every node in an earlier tier is implicitly deployed, and waited on
+// if it has a WaitReady step, before any node in a later tier starts. Addon
+// nodes use AddOption's DependsOn to add edges within or across a tier.
+type dagTier int
+
+const (
+	tierNamespace dagTier = iota
+	tierCRD
+	tierNamedConfig // ServiceAccounts, RBAC, ConfigMaps, Secrets
+	tierWorkload    // ReplicationControllers, Pods, Deployments
+	tierService
+)
+
+// dagNode is a single object registered with a Deployer via one of its Add*
+// methods, along with what it depends on and how to deploy/wait for it.
+type dagNode struct {
+	name          string
+	kind          string
+	componentType utils.ComponentType
+	tier          dagTier
+	dependsOn     []string
+	deploy        func(cd *Deployer) error
+	waitReady     func(cd *Deployer) error
+}
+
+// AddOption customizes how an object registered via one of the Deployer's
+// Add* methods participates in the dependency graph built by Run.
+type AddOption func(*dagNode)
+
+// DependsOn declares that the object being added must not be deployed (and,
+// if it has a WaitReady step, must not be considered ready) until the named
+// objects - of any kind - have themselves been successfully deployed and
+// become ready. Names that match no registered object, or more than one
+// (e.g. a ConfigMap and a Service sharing a name), are ignored, since
+// cross-kind name collisions mean the safest behavior is to skip rather
+// than guess.
+func DependsOn(names ...string) AddOption {
+	return func(n *dagNode) {
+		n.dependsOn = append(n.dependsOn, names...)
+	}
+}
+
+// addNode registers a node in the Deployer's dependency graph, applying opts
+// on top of the tier-implied defaults.
+func (d *Deployer) addNode(name, kind string, componentType utils.ComponentType, tier dagTier,
+	deploy func(cd *Deployer) error, waitReady func(cd *Deployer) error, opts []AddOption) {
+
+	n := &dagNode{
+		name:          name,
+		kind:          kind,
+		componentType: componentType,
+		tier:          tier,
+		deploy:        deploy,
+		waitReady:     waitReady,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	d.graph = append(d.graph, n)
+}
+
+// runDAG deploys every node registered on d against cd's clientsets,
+// honoring each node's tier and explicit DependsOn edges. Nodes with no
+// outstanding dependencies run concurrently, bounded by
+// maxConcurrentDAGWorkers. A node whose dependency failed (or was itself
+// skipped) is never deployed; it's recorded as skipped-due-to-dependency so
+// the returned errors are actionable. allowed, if non-nil, is consulted
+// before each node is deployed (e.g. to restrict CRDs to a control plane
+// cluster via a ClusterFilter); a disallowed node is treated as a no-op,
+// neither deployed nor marked failed, so its dependents still proceed.
+func (d *Deployer) runDAG(cd *Deployer, allowed func(utils.ComponentType) bool) map[utils.ComponentType][]error {
+	errs := map[utils.ComponentType][]error{}
+	if len(d.graph) == 0 {
+		return errs
+	}
+
+	// name is only unique within one Add* method's map (d.configMaps,
+	// d.services, ...), so a ConfigMap and a Service named after the same
+	// component is perfectly legal. Node identity for the graph is therefore
+	// the *dagNode itself, never its bare name; byName exists only to
+	// resolve DependsOn, and a name matching more than one node is treated
+	// the same as a name matching none (see DependsOn's doc comment).
+	byName := map[string][]*dagNode{}
+	for _, n := range d.graph {
+		byName[n.name] = append(byName[n.name], n)
+	}
+	resolve := func(name string) *dagNode {
+		candidates := byName[name]
+		if len(candidates) != 1 {
+			return nil
+		}
+		return candidates[0]
+	}
+
+	deps := map[*dagNode][]*dagNode{}
+	pending := map[*dagNode]int{}
+	dependents := map[*dagNode][]*dagNode{}
+	for _, n := range d.graph {
+		nodeDeps := map[*dagNode]bool{}
+		for _, other := range d.graph {
+			if other.tier < n.tier {
+				nodeDeps[other] = true
+			}
+		}
+		for _, name := range n.dependsOn {
+			if dep := resolve(name); dep != nil {
+				nodeDeps[dep] = true
+			}
+		}
+		for dep := range nodeDeps {
+			deps[n] = append(deps[n], dep)
+			dependents[dep] = append(dependents[dep], n)
+		}
+		pending[n] = len(nodeDeps)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentDAGWorkers)
+	failed := map[*dagNode]bool{}
+	processed := map[*dagNode]bool{}
+
+	var dispatch func(n *dagNode)
+	dispatch = func(n *dagNode) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			blocked := false
+			for _, dep := range deps[n] {
+				if failed[dep] {
+					blocked = true
+					break
+				}
+			}
+			mu.Unlock()
+
+			if blocked {
+				log.Warnf("Skipping %s %s: a dependency failed to deploy or become ready", n.kind, n.name)
+				mu.Lock()
+				failed[n] = true
+				errs[n.componentType] = append(errs[n.componentType], fmt.Errorf("%s %s: skipped due to failed dependency", n.kind, n.name))
+				mu.Unlock()
+			} else if allowed != nil && !allowed(n.componentType) {
+				// Filtered out for this cluster; not deployed, not failed.
+			} else if err := n.deploy(cd); err != nil {
+				mu.Lock()
+				failed[n] = true
+				errs[n.componentType] = append(errs[n.componentType], err)
+				mu.Unlock()
+			} else if n.waitReady != nil {
+				if err := n.waitReady(cd); err != nil {
+					mu.Lock()
+					failed[n] = true
+					errs[n.componentType] = append(errs[n.componentType], fmt.Errorf("%s %s: never became ready: %v", n.kind, n.name, err))
+					mu.Unlock()
+				}
+			}
+
+			mu.Lock()
+			processed[n] = true
+			ready := []*dagNode{}
+			for _, dep := range dependents[n] {
+				pending[dep]--
+				if pending[dep] == 0 {
+					ready = append(ready, dep)
+				}
+			}
+			mu.Unlock()
+
+			for _, r := range ready {
+				dispatch(r)
+			}
+		}()
+	}
+
+	initiallyReady := []*dagNode{}
+	for n, count := range pending {
+		if count == 0 {
+			initiallyReady = append(initiallyReady, n)
+		}
+	}
+	for _, n := range initiallyReady {
+		dispatch(n)
+	}
+	wg.Wait()
+
+	for _, n := range d.graph {
+		if !processed[n] {
+			log.Errorf("%s %s was never deployed: its dependencies form a cycle", n.kind, n.name)
+			errs[n.componentType] = append(errs[n.componentType], fmt.Errorf("%s %s: dependency cycle, never deployed", n.kind, n.name))
+		}
+	}
+
+	return errs
+}
+
+// waitForReady polls condition with an exponential backoff until it reports
+// ready, returns an error, or timeout elapses.
+func waitForReady(timeout time.Duration, condition func() (bool, error)) error {
+	backoff := wait.Backoff{Duration: 500 * time.Millisecond, Factor: 2, Steps: 12, Cap: 15 * time.Second}
+	deadline := time.Now().Add(timeout)
+
+	return wait.ExponentialBackoff(backoff, func() (bool, error) {
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("timed out after %s waiting to become ready", timeout)
+		}
+		return condition()
+	})
+}
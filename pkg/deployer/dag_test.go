@@ -0,0 +1,194 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
+)
+
+// recorder tracks deploy/waitReady calls made by runDAG across goroutines.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, s)
+}
+
+func (r *recorder) has(s string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.calls {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
+
+// node builds a dagNode whose deploy step records deployKey on rec and
+// succeeds, for use directly in a test's d.graph (runDAG only reads dagNode
+// fields, so no Deployer.Add* call is needed to build one).
+func node(name, kind string, ct utils.ComponentType, tier dagTier, rec *recorder, deployKey string, opts ...AddOption) *dagNode {
+	n := &dagNode{
+		name:          name,
+		kind:          kind,
+		componentType: ct,
+		tier:          tier,
+		deploy: func(cd *Deployer) error {
+			rec.record(deployKey)
+			return nil
+		},
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func TestRunDAG_SameNameAcrossKindsBothDeploy(t *testing.T) {
+	// A ConfigMap and a Service both named "app" is legal and common;
+	// neither node's identity should be clobbered by the other's.
+	rec := &recorder{}
+	d := &Deployer{graph: []*dagNode{
+		node("app", "config map", utils.ConfigMapComponent, tierNamedConfig, rec, "configmap:app"),
+		node("app", "service", utils.ServiceComponent, tierService, rec, "service:app"),
+	}}
+
+	errs := d.runDAG(&Deployer{}, nil)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !rec.has("configmap:app") {
+		t.Errorf("config map %q was never deployed", "app")
+	}
+	if !rec.has("service:app") {
+		t.Errorf("service %q was never deployed", "app")
+	}
+}
+
+func TestRunDAG_TierOrdering(t *testing.T) {
+	rec := &recorder{}
+	var mu sync.Mutex
+	var order []string
+	track := func(key string, n *dagNode) {
+		n.deploy = func(cd *Deployer) error {
+			mu.Lock()
+			order = append(order, key)
+			mu.Unlock()
+			rec.record(key)
+			return nil
+		}
+	}
+
+	namespaceNode := node("ns", "namespace", utils.NamespaceComponent, tierNamespace, rec, "ns")
+	configNode := node("cfg", "config map", utils.ConfigMapComponent, tierNamedConfig, rec, "cfg")
+	workloadNode := node("dep", "deployment", utils.DeploymentComponent, tierWorkload, rec, "dep")
+	track("ns", namespaceNode)
+	track("cfg", configNode)
+	track("dep", workloadNode)
+
+	d := &Deployer{graph: []*dagNode{workloadNode, configNode, namespaceNode}}
+	errs := d.runDAG(&Deployer{}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	mu.Lock()
+	got := append([]string{}, order...)
+	mu.Unlock()
+	want := []string{"ns", "cfg", "dep"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("deploy order = %v, want %v", got, want)
+	}
+}
+
+func TestRunDAG_FailedDependencySkipsDependents(t *testing.T) {
+	rec := &recorder{}
+	failing := node("bad", "config map", utils.ConfigMapComponent, tierNamedConfig, rec, "bad")
+	failing.deploy = func(cd *Deployer) error { return fmt.Errorf("boom") }
+	dependent := node("dependent", "deployment", utils.DeploymentComponent, tierWorkload, rec, "dependent")
+	independent := node("other-ns", "namespace", utils.NamespaceComponent, tierNamespace, rec, "other-ns")
+
+	d := &Deployer{graph: []*dagNode{failing, dependent, independent}}
+	errs := d.runDAG(&Deployer{}, nil)
+
+	if rec.has("dependent") {
+		t.Errorf("dependent was deployed despite its tier dependency failing")
+	}
+	if !rec.has("other-ns") {
+		t.Errorf("independent node was skipped even though it had no failed dependency")
+	}
+	if len(errs[utils.ConfigMapComponent]) == 0 {
+		t.Errorf("expected an error recorded for the failing node's component type")
+	}
+	if len(errs[utils.DeploymentComponent]) == 0 {
+		t.Errorf("expected a skipped-due-to-dependency error for the dependent node")
+	}
+}
+
+func TestRunDAG_AmbiguousDependsOnNameIsIgnored(t *testing.T) {
+	// Two nodes share the name "app"; a third node's DependsOn("app") can't
+	// tell which one it means, so it must not block on either.
+	rec := &recorder{}
+	configNode := node("app", "config map", utils.ConfigMapComponent, tierNamedConfig, rec, "configmap:app")
+	serviceNode := node("app", "service", utils.ServiceComponent, tierService, rec, "service:app")
+	waiter := node("waiter", "deployment", utils.DeploymentComponent, tierNamespace, rec, "waiter", DependsOn("app"))
+
+	d := &Deployer{graph: []*dagNode{configNode, serviceNode, waiter}}
+	errs := d.runDAG(&Deployer{}, nil)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !rec.has("waiter") {
+		t.Errorf("waiter was never deployed; an unresolvable DependsOn name should be ignored, not block forever")
+	}
+}
+
+func TestRunDAG_ClusterFilterSkipsWithoutFailing(t *testing.T) {
+	rec := &recorder{}
+	filtered := node("crd", "custom resource definition", utils.CRDComponent, tierCRD, rec, "crd")
+	downstream := node("dep", "deployment", utils.DeploymentComponent, tierWorkload, rec, "dep")
+
+	d := &Deployer{graph: []*dagNode{filtered, downstream}}
+	allowed := func(ct utils.ComponentType) bool { return ct != utils.CRDComponent }
+	errs := d.runDAG(&Deployer{}, allowed)
+
+	if rec.has("crd") {
+		t.Errorf("filtered-out node was deployed")
+	}
+	if !rec.has("dep") {
+		t.Errorf("downstream node was blocked by a filtered (not failed) dependency")
+	}
+	if len(errs) != 0 {
+		t.Errorf("a filtered-out node must not be recorded as an error, got %v", errs)
+	}
+}
@@ -22,19 +22,19 @@ under the License.
 package deployer
 
 import (
+	"sync"
+
 	"github.com/blackducksoftware/cn-crd-controller/pkg/api"
 	"github.com/blackducksoftware/cn-crd-controller/pkg/types"
 	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
 	utilserror "github.com/blackducksoftware/cn-crd-controller/pkg/utils/error"
 
-	"github.com/koki/short/converter/converters"
 	shorttypes "github.com/koki/short/types"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 
 	extensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-
-	log "github.com/sirupsen/logrus"
 )
 
 // Deployer handles deploying the components to a cluster
@@ -55,6 +55,16 @@ type Deployer struct {
 
 	client        *kubernetes.Clientset
 	apiextensions *extensionsclient.Clientset
+
+	applyMode ApplyMode
+
+	registry      *ClusterRegistry
+	clusterFilter ClusterFilter
+
+	graph []*dagNode
+
+	kindHandlers  map[schema.GroupVersionKind]KindHandler
+	customObjects map[schema.GroupVersionKind]map[string]interface{}
 }
 
 // NewDeployer creates a Deployer object
@@ -63,18 +73,21 @@ func NewDeployer(client *kubernetes.Clientset, apiextensions *extensionsclient.C
 		client:                 client,
 		apiextensions:          apiextensions,
 		replicationControllers: make(map[string]*shorttypes.ReplicationController),
-		pods:                make(map[string]*shorttypes.Pod),
-		configMaps:          make(map[string]*shorttypes.ConfigMap),
-		secrets:             make(map[string]*shorttypes.Secret),
-		services:            make(map[string]*shorttypes.Service),
-		serviceAccounts:     make(map[string]*shorttypes.ServiceAccount),
-		deployments:         make(map[string]*shorttypes.Deployment),
-		clusterRoles:        make(map[string]*shorttypes.ClusterRole),
-		clusterRoleBindings: make(map[string]*shorttypes.ClusterRoleBinding),
-		crds:                make(map[string]*shorttypes.CustomResourceDefinition),
-		namespaces:          make(map[string]*shorttypes.Namespace),
-		controllers:         make(map[string]api.DeployerControllerInterface),
+		pods:                   make(map[string]*shorttypes.Pod),
+		configMaps:             make(map[string]*shorttypes.ConfigMap),
+		secrets:                make(map[string]*shorttypes.Secret),
+		services:               make(map[string]*shorttypes.Service),
+		serviceAccounts:        make(map[string]*shorttypes.ServiceAccount),
+		deployments:            make(map[string]*shorttypes.Deployment),
+		clusterRoles:           make(map[string]*shorttypes.ClusterRole),
+		clusterRoleBindings:    make(map[string]*shorttypes.ClusterRoleBinding),
+		crds:                   make(map[string]*shorttypes.CustomResourceDefinition),
+		namespaces:             make(map[string]*shorttypes.Namespace),
+		controllers:            make(map[string]api.DeployerControllerInterface),
+		kindHandlers:           make(map[schema.GroupVersionKind]KindHandler),
+		customObjects:          make(map[schema.GroupVersionKind]map[string]interface{}),
 	}
+	registerBuiltinKindHandlers(&d)
 	return &d
 }
 
@@ -84,117 +97,182 @@ func (d *Deployer) AddController(name string, c api.DeployerControllerInterface)
 	d.controllers[name] = c
 }
 
-// AddConfigMap will add the provided config map to the config maps
-// that will be deployed
-func (d *Deployer) AddConfigMap(obj *types.ConfigMap) {
-	d.configMaps[obj.GetName()] = obj.GetObj()
+// AddConfigMap will add the provided config map to the config maps that
+// will be deployed. By default it deploys alongside every other ConfigMap,
+// Secret, ServiceAccount and RBAC object once all Namespaces and CRDs are
+// ready; pass DependsOn to wait on specific other objects first.
+func (d *Deployer) AddConfigMap(obj *types.ConfigMap, opts ...AddOption) {
+	name := obj.GetName()
+	d.configMaps[name] = obj.GetObj()
+	d.addNode(name, "config map", utils.ConfigMapComponent, tierNamedConfig,
+		func(cd *Deployer) error { return cd.deployTyped(ConfigMapGVK, name, obj.GetObj()) }, nil, opts)
 }
 
-// AddDeployment will add the provided deployment to the deployments
-// that will be deployed
-func (d *Deployer) AddDeployment(obj *types.Deployment) {
-	d.deployments[obj.GetName()] = obj.GetObj()
+// AddDeployment will add the provided deployment to the deployments that
+// will be deployed. By default it deploys once all Namespaces, CRDs,
+// ServiceAccounts, RBAC, ConfigMaps and Secrets are ready, and Run waits for
+// it to become Available before considering it ready; pass DependsOn to
+// wait on specific other objects first.
+func (d *Deployer) AddDeployment(obj *types.Deployment, opts ...AddOption) {
+	name := obj.GetName()
+	d.deployments[name] = obj.GetObj()
+	d.addNode(name, "deployment", utils.DeploymentComponent, tierWorkload,
+		func(cd *Deployer) error { return cd.deployTyped(DeploymentGVK, name, obj.GetObj()) },
+		func(cd *Deployer) error { return cd.waitTyped(DeploymentGVK, name, obj.GetObj()) }, opts)
 }
 
-// AddService will add the provided service to the services
-// that will be deployed
-func (d *Deployer) AddService(obj *types.Service) {
-	d.services[obj.GetName()] = obj.GetObj()
+// AddService will add the provided service to the services that will be
+// deployed. By default it deploys last, once every Pod, ReplicationController
+// and Deployment is ready, and Run waits for it to have populated endpoints;
+// pass DependsOn to wait on specific other objects first.
+func (d *Deployer) AddService(obj *types.Service, opts ...AddOption) {
+	name := obj.GetName()
+	d.services[name] = obj.GetObj()
+	d.addNode(name, "service", utils.ServiceComponent, tierService,
+		func(cd *Deployer) error { return cd.deployTyped(ServiceGVK, name, obj.GetObj()) },
+		func(cd *Deployer) error { return cd.waitTyped(ServiceGVK, name, obj.GetObj()) }, opts)
 }
 
-// AddSecret will add the provided secret to the secrets
-// that will be deployed
-func (d *Deployer) AddSecret(obj *types.Secret) {
-	d.secrets[obj.GetName()] = obj.GetObj()
+// AddServiceAccount will add the provided service account to the service
+// accounts that will be deployed. By default it deploys alongside RBAC,
+// ConfigMaps and Secrets once all Namespaces and CRDs are ready; pass
+// DependsOn to wait on specific other objects first.
+func (d *Deployer) AddServiceAccount(obj *types.ServiceAccount, opts ...AddOption) {
+	name := obj.GetName()
+	d.serviceAccounts[name] = obj.GetObj()
+	d.addNode(name, "service account", utils.ServiceAccountComponent, tierNamedConfig,
+		func(cd *Deployer) error { return cd.deployTyped(ServiceAccountGVK, name, obj.GetObj()) }, nil, opts)
 }
 
-// AddClusterRole will add the provided cluster role to the
-// cluster roles that will be deployed
-func (d *Deployer) AddClusterRole(obj *types.ClusterRole) {
-	d.clusterRoles[obj.GetName()] = obj.GetObj()
+// AddSecret will add the provided secret to the secrets that will be
+// deployed. By default it deploys alongside ConfigMaps, ServiceAccounts and
+// RBAC once all Namespaces and CRDs are ready; pass DependsOn to wait on
+// specific other objects first.
+func (d *Deployer) AddSecret(obj *types.Secret, opts ...AddOption) {
+	name := obj.GetName()
+	d.secrets[name] = obj.GetObj()
+	d.addNode(name, "secret", utils.SecretComponent, tierNamedConfig,
+		func(cd *Deployer) error { return cd.deployTyped(SecretGVK, name, obj.GetObj()) }, nil, opts)
 }
 
-// AddClusterRoleBinding will add the provided cluster role binding
-// to the cluster role bindings that will be deployed
-func (d *Deployer) AddClusterRoleBinding(obj *types.ClusterRoleBinding) {
-	d.clusterRoleBindings[obj.GetName()] = obj.GetObj()
+// AddClusterRole will add the provided cluster role to the cluster roles
+// that will be deployed. By default it deploys alongside ServiceAccounts,
+// ConfigMaps and Secrets once all Namespaces and CRDs are ready; pass
+// DependsOn to wait on specific other objects first.
+func (d *Deployer) AddClusterRole(obj *types.ClusterRole, opts ...AddOption) {
+	name := obj.GetName()
+	d.clusterRoles[name] = obj.GetObj()
+	d.addNode(name, "cluster role", utils.ClusterRoleComponent, tierNamedConfig,
+		func(cd *Deployer) error { return cd.deployTyped(ClusterRoleGVK, name, obj.GetObj()) }, nil, opts)
 }
 
-// AddCustomDefinedResource will add the provided custom defined resource
-// to the custom defined resources that will be deployed
-func (d *Deployer) AddCustomDefinedResource(obj *types.CustomResourceDefinition) {
-	d.crds[obj.GetName()] = obj.GetObj()
+// AddClusterRoleBinding will add the provided cluster role binding to the
+// cluster role bindings that will be deployed. By default it deploys
+// alongside ServiceAccounts, ConfigMaps and Secrets once all Namespaces and
+// CRDs are ready; pass DependsOn to wait on specific other objects first.
+func (d *Deployer) AddClusterRoleBinding(obj *types.ClusterRoleBinding, opts ...AddOption) {
+	name := obj.GetName()
+	d.clusterRoleBindings[name] = obj.GetObj()
+	d.addNode(name, "cluster role binding", utils.ClusterRoleBindingComponent, tierNamedConfig,
+		func(cd *Deployer) error { return cd.deployTyped(ClusterRoleBindingGVK, name, obj.GetObj()) }, nil, opts)
 }
 
-// AddReplicationConroller will add the provided replication controller
-// to the replication controllers that will be deployed
-func (d *Deployer) AddReplicationConroller(obj *types.ReplicationController) {
-	d.replicationControllers[obj.GetName()] = obj.GetObj()
+// AddCustomDefinedResource will add the provided custom defined resource to
+// the custom defined resources that will be deployed. By default it deploys
+// once all Namespaces are ready, and Run waits for its Established condition
+// before anything that depends on it (e.g. ServiceAccounts, Deployments) is
+// allowed to proceed; pass DependsOn to wait on specific other objects
+// first.
+func (d *Deployer) AddCustomDefinedResource(obj *types.CustomResourceDefinition, opts ...AddOption) {
+	name := obj.GetName()
+	d.crds[name] = obj.GetObj()
+	d.addNode(name, "custom resource definition", utils.CRDComponent, tierCRD,
+		func(cd *Deployer) error { return cd.deployTyped(CRDGVK, name, obj.GetObj()) },
+		func(cd *Deployer) error { return cd.waitTyped(CRDGVK, name, obj.GetObj()) }, opts)
 }
 
-// AddNamespace will add the provided namespace to the
-// namespaces that will be deployed
-func (d *Deployer) AddNamespace(obj *types.Namespace) {
-	d.namespaces[obj.GetName()] = obj.GetObj()
+// AddReplicationConroller will add the provided replication controller to
+// the replication controllers that will be deployed. By default it deploys
+// once all Namespaces, CRDs, ServiceAccounts, RBAC, ConfigMaps and Secrets
+// are ready; pass DependsOn to wait on specific other objects first.
+func (d *Deployer) AddReplicationConroller(obj *types.ReplicationController, opts ...AddOption) {
+	name := obj.GetName()
+	d.replicationControllers[name] = obj.GetObj()
+	d.addNode(name, "replication controller", utils.ReplicationControllerComponent, tierWorkload,
+		func(cd *Deployer) error { return cd.deployTyped(ReplicationControllerGVK, name, obj.GetObj()) }, nil, opts)
 }
 
-// Run starts the deployer and deploys all components to the cluster
-func (d *Deployer) Run() error {
-	allErrs := map[utils.ComponentType][]error{}
-
-	err := d.deployNamespaces()
-	if len(err) > 0 {
-		allErrs[utils.NamespaceComponent] = err
-	}
-
-	err = d.deployCRDs()
-	if len(err) > 0 {
-		allErrs[utils.CRDComponent] = err
-	}
-
-	err = d.deployServiceAccounts()
-	if len(err) > 0 {
-		allErrs[utils.ServiceAccountComponent] = err
-	}
+// AddPod will add the provided pod to the pods that will be deployed. By
+// default it deploys under the same Workload tier as ReplicationControllers
+// and Deployments, once all Namespaces, CRDs, ServiceAccounts, RBAC,
+// ConfigMaps and Secrets are ready, and Run waits for it to reach phase
+// Running with a True PodReady condition before considering it ready; pass
+// DependsOn to wait on specific other objects first.
+func (d *Deployer) AddPod(obj *types.Pod, opts ...AddOption) {
+	name := obj.GetName()
+	d.pods[name] = obj.GetObj()
+	d.addNode(name, "pod", utils.PodComponent, tierWorkload,
+		func(cd *Deployer) error { return cd.deployTyped(PodGVK, name, obj.GetObj()) },
+		func(cd *Deployer) error { return cd.waitTyped(PodGVK, name, obj.GetObj()) }, opts)
+}
 
-	errMap := d.deployRBAC()
-	if len(errMap) > 0 {
-		for k, v := range errMap {
-			allErrs[k] = v
-		}
-	}
+// AddNamespace will add the provided namespace to the namespaces that will
+// be deployed. Namespaces are always the first tier deployed, and Run waits
+// for them to become Active before anything else proceeds; pass DependsOn
+// to wait on specific other Namespaces first.
+func (d *Deployer) AddNamespace(obj *types.Namespace, opts ...AddOption) {
+	name := obj.GetName()
+	d.namespaces[name] = obj.GetObj()
+	d.addNode(name, "namespace", utils.NamespaceComponent, tierNamespace,
+		func(cd *Deployer) error { return cd.deployTyped(NamespaceGVK, name, obj.GetObj()) },
+		func(cd *Deployer) error { return cd.waitTyped(NamespaceGVK, name, obj.GetObj()) }, opts)
+}
 
-	err = d.deployConfigMaps()
-	if len(err) > 0 {
-		allErrs[utils.ConfigMapComponent] = err
+// Run starts the deployer and deploys all registered components to the
+// cluster, respecting the dependency graph built from each Add* call (see
+// DependsOn). If a ClusterRegistry has been attached via SetClusterRegistry,
+// Run additionally fans the same components out to every cluster currently
+// known to the registry, running up to maxConcurrentClusterDeploys deploys
+// at once, and returns a ClusterDeployErrors keyed by cluster name. With no
+// registry attached, Run deploys only through the Deployer's own client and
+// returns a DeployErrors for it. Callers that want to preview
+// what Run would do, or gate it on a clean diff, should call DryRun first.
+func (d *Deployer) Run() error {
+	if d.registry == nil {
+		return utilserror.NewDeployErrors(d.runForCluster(&Cluster{
+			Name:          localClusterName,
+			Client:        d.client,
+			APIExtensions: d.apiextensions,
+		}))
 	}
 
-	err = d.deploySecrets()
-	if len(err) > 0 {
-		allErrs[utils.SecretComponent] = err
-	}
+	targets := append([]*Cluster{{Name: localClusterName, Client: d.client, APIExtensions: d.apiextensions}}, d.registry.Clusters()...)
 
-	err = d.deployReplicationControllers()
-	if len(err) > 0 {
-		allErrs[utils.ReplicationControllerComponent] = err
-	}
+	sem := make(chan struct{}, maxConcurrentClusterDeploys)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	clusterErrs := ClusterDeployErrors{}
 
-	err = d.deployPods()
-	if len(err) > 0 {
-		allErrs[utils.PodComponent] = err
-	}
+	for _, cluster := range targets {
+		wg.Add(1)
+		go func(cluster *Cluster) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-	err = d.deployDeployments()
-	if len(err) > 0 {
-		allErrs[utils.DeploymentComponent] = err
+			if errMap := d.runForCluster(cluster); len(errMap) > 0 {
+				mu.Lock()
+				clusterErrs[cluster.Name] = utilserror.NewDeployErrors(errMap)
+				mu.Unlock()
+			}
+		}(cluster)
 	}
+	wg.Wait()
 
-	err = d.deployServices()
-	if len(err) > 0 {
-		allErrs[utils.ServiceComponent] = err
+	if len(clusterErrs) == 0 {
+		return nil
 	}
-
-	return utilserror.NewDeployErrors(allErrs)
+	return clusterErrs
 }
 
 // StartControllers will start all the configured controllers
@@ -228,200 +306,88 @@ func (d *Deployer) StartControllers(stopCh chan struct{}) map[string][]error {
 	return errs
 }
 
-func (d *Deployer) deployCRDs() []error {
-	errs := []error{}
+// Undo removes every component previously applied by this Deployer from its
+// own client/apiextensions, identified via the last-applied-configuration
+// annotation set by Run when running in ApplyModeUpdate. Components that
+// were never applied by this Deployer (no annotation present) are left
+// untouched. It does not touch a ClusterRegistry's remote clusters; those
+// are torn down automatically via undoForCluster when a cluster's secret is
+// removed (see SetClusterRegistry).
+func (d *Deployer) Undo() error {
+	allErrs := map[utils.ComponentType][]error{}
 
-	for name, crdObj := range d.crds {
-		wrapper := &shorttypes.CRDWrapper{CRD: *crdObj}
-		crd, err := converters.Convert_Koki_CRD_to_Kube(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
-		log.Infof("Creating custom defined resource %s", name)
-		_, err = d.apiextensions.ApiextensionsV1beta1().CustomResourceDefinitions().Create(crd)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.services {
+		if err := d.deleteTyped(ServiceGVK, name, obj); err != nil {
+			allErrs[utils.ServiceComponent] = append(allErrs[utils.ServiceComponent], err)
 		}
 	}
-	return errs
-}
 
-func (d *Deployer) deployServiceAccounts() []error {
-	errs := []error{}
-
-	for name, saObj := range d.serviceAccounts {
-		wrapper := &shorttypes.ServiceAccountWrapper{ServiceAccount: *saObj}
-		sa, err := converters.Convert_Koki_ServiceAccount_to_Kube_ServiceAccount(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
-		log.Infof("Creating service account %s", name)
-		_, err = d.client.Core().ServiceAccounts(sa.Namespace).Create(sa)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.deployments {
+		if err := d.deleteTyped(DeploymentGVK, name, obj); err != nil {
+			allErrs[utils.DeploymentComponent] = append(allErrs[utils.DeploymentComponent], err)
 		}
 	}
-	return errs
-}
 
-func (d *Deployer) deployRBAC() map[utils.ComponentType][]error {
-	errs := map[utils.ComponentType][]error{}
-
-	for name, crObj := range d.clusterRoles {
-		wrapper := &shorttypes.ClusterRoleWrapper{ClusterRole: *crObj}
-		cr, err := converters.Convert_Koki_ClusterRole_to_Kube(wrapper)
-		if err != nil {
-			errs[utils.ClusterRoleComponent] = append(errs[utils.ClusterRoleComponent], err)
-		}
-		log.Infof("Creating cluster role %s", name)
-		_, err = d.client.Rbac().ClusterRoles().Create(cr)
-		if err != nil {
-			errs[utils.ClusterRoleComponent] = append(errs[utils.ClusterRoleComponent], err)
+	for name, obj := range d.pods {
+		if err := d.deleteTyped(PodGVK, name, obj); err != nil {
+			allErrs[utils.PodComponent] = append(allErrs[utils.PodComponent], err)
 		}
 	}
 
-	for name, crbObj := range d.clusterRoleBindings {
-		wrapper := &shorttypes.ClusterRoleBindingWrapper{ClusterRoleBinding: *crbObj}
-		crb, err := converters.Convert_Koki_ClusterRoleBinding_to_Kube(wrapper)
-		if err != nil {
-			errs[utils.ClusterRoleBindingComponent] = append(errs[utils.ClusterRoleComponent], err)
-		}
-		log.Infof("Creating cluster role binding %s", name)
-		_, err = d.client.Rbac().ClusterRoleBindings().Create(crb)
-		if err != nil {
-			errs[utils.ClusterRoleBindingComponent] = append(errs[utils.ClusterRoleComponent], err)
+	for name, obj := range d.replicationControllers {
+		if err := d.deleteTyped(ReplicationControllerGVK, name, obj); err != nil {
+			allErrs[utils.ReplicationControllerComponent] = append(allErrs[utils.ReplicationControllerComponent], err)
 		}
 	}
-	return errs
-}
 
-func (d *Deployer) deployConfigMaps() []error {
-	errs := []error{}
-
-	for name, cmObj := range d.configMaps {
-		wrapper := &shorttypes.ConfigMapWrapper{ConfigMap: *cmObj}
-		cm, err := converters.Convert_Koki_ConfigMap_to_Kube_v1_ConfigMap(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
-		log.Infof("Creating config map %s", name)
-		_, err = d.client.Core().ConfigMaps(cm.Namespace).Create(cm)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.secrets {
+		if err := d.deleteTyped(SecretGVK, name, obj); err != nil {
+			allErrs[utils.SecretComponent] = append(allErrs[utils.SecretComponent], err)
 		}
 	}
-	return errs
-}
 
-func (d *Deployer) deploySecrets() []error {
-	errs := []error{}
-
-	for name, secretObj := range d.secrets {
-		wrapper := &shorttypes.SecretWrapper{Secret: *secretObj}
-		secret, err := converters.Convert_Koki_Secret_to_Kube_v1_Secret(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
-		log.Infof("Creating secret %s", name)
-		_, err = d.client.Core().Secrets(secret.Namespace).Create(secret)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.configMaps {
+		if err := d.deleteTyped(ConfigMapGVK, name, obj); err != nil {
+			allErrs[utils.ConfigMapComponent] = append(allErrs[utils.ConfigMapComponent], err)
 		}
 	}
-	return errs
-}
-
-func (d *Deployer) deployReplicationControllers() []error {
-	errs := []error{}
-
-	for name, rcObj := range d.replicationControllers {
-		wrapper := &shorttypes.ReplicationControllerWrapper{ReplicationController: *rcObj}
-		rc, err := converters.Convert_Koki_ReplicationController_to_Kube_v1_ReplicationController(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
 
-		log.Infof("Creating replication controller %s", name)
-		_, err = d.client.Core().ReplicationControllers(rc.Namespace).Create(rc)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.clusterRoles {
+		if err := d.deleteTyped(ClusterRoleGVK, name, obj); err != nil {
+			allErrs[utils.ClusterRoleComponent] = append(allErrs[utils.ClusterRoleComponent], err)
 		}
 	}
-	return errs
-}
-
-func (d *Deployer) deployPods() []error {
-	errs := []error{}
-
-	for name, pObj := range d.pods {
-		wrapper := &shorttypes.PodWrapper{Pod: *pObj}
-		pod, err := converters.Convert_Koki_Pod_to_Kube_v1_Pod(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
 
-		log.Infof("Creating pod %s", name)
-		_, err = d.client.Core().Pods(pod.Namespace).Create(pod)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.clusterRoleBindings {
+		if err := d.deleteTyped(ClusterRoleBindingGVK, name, obj); err != nil {
+			allErrs[utils.ClusterRoleBindingComponent] = append(allErrs[utils.ClusterRoleBindingComponent], err)
 		}
 	}
-	return errs
-}
 
-func (d *Deployer) deployDeployments() []error {
-	errs := []error{}
-
-	for name, dObj := range d.deployments {
-		wrapper := &shorttypes.DeploymentWrapper{Deployment: *dObj}
-		deploy, err := converters.Convert_Koki_Deployment_to_Kube_apps_v1beta2_Deployment(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
-
-		log.Infof("Creating deployment %s", name)
-		_, err = d.client.AppsV1beta2().Deployments(deploy.Namespace).Create(deploy)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.serviceAccounts {
+		if err := d.deleteTyped(ServiceAccountGVK, name, obj); err != nil {
+			allErrs[utils.ServiceAccountComponent] = append(allErrs[utils.ServiceAccountComponent], err)
 		}
 	}
-	return errs
-}
-
-func (d *Deployer) deployServices() []error {
-	errs := []error{}
 
-	for name, svcObj := range d.services {
-		sWrapper := &shorttypes.ServiceWrapper{Service: *svcObj}
-		svc, err := converters.Convert_Koki_Service_To_Kube_v1_Service(sWrapper)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.crds {
+		if err := d.deleteTyped(CRDGVK, name, obj); err != nil {
+			allErrs[utils.CRDComponent] = append(allErrs[utils.CRDComponent], err)
 		}
+	}
 
-		log.Infof("Creating service %s", name)
-		_, err = d.client.Core().Services(svc.Namespace).Create(svc)
-		if err != nil {
-			errs = append(errs, err)
+	for name, obj := range d.namespaces {
+		if err := d.deleteTyped(NamespaceGVK, name, obj); err != nil {
+			allErrs[utils.NamespaceComponent] = append(allErrs[utils.NamespaceComponent], err)
 		}
 	}
-	return errs
-}
 
-func (d *Deployer) deployNamespaces() []error {
-	errs := []error{}
-
-	for name, nsObj := range d.namespaces {
-		wrapper := &shorttypes.NamespaceWrapper{Namespace: *nsObj}
-		ns, err := converters.Convert_Koki_Namespace_to_Kube_Namespace(wrapper)
-		if err != nil {
-			errs = append(errs, err)
-		}
-		log.Infof("Creating namespace %s", name)
-		_, err = d.client.Core().Namespaces().Create(ns)
-		if err != nil {
-			errs = append(errs, err)
+	errMap := d.undeployCustomResources()
+	if len(errMap) > 0 {
+		for k, v := range errMap {
+			allErrs[k] = append(allErrs[k], v...)
 		}
 	}
 
-	return errs
+	return utilserror.NewDeployErrors(allErrs)
 }
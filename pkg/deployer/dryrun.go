@@ -0,0 +1,505 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
+	utilserror "github.com/blackducksoftware/cn-crd-controller/pkg/utils/error"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// DryRunMode controls how thoroughly DryRun checks a would-be deploy.
+type DryRunMode int
+
+const (
+	// DryRunClient only compares desired objects against what's live
+	// locally; it never talks to the API server beyond the Get calls
+	// needed to know what's live.
+	DryRunClient DryRunMode = iota
+	// DryRunServer additionally submits the same create-or-patch this
+	// Deployer's ApplyModeUpdate would send, with the dryRun=All query
+	// parameter set, so admission webhooks and validation run without
+	// persisting anything.
+	DryRunServer
+	// DryRunServerApply is like DryRunServer, but submits the create-or-
+	// patch as a server-side apply patch (the same mechanism behind
+	// `kubectl apply --server-side`) instead of this Deployer's own
+	// three-way merge.
+	DryRunServerApply
+)
+
+// fieldManager identifies this Deployer as the owner of fields set via a
+// DryRunServerApply server-side-apply patch.
+const fieldManager = "horizon"
+
+// ComponentDiff is the result of comparing a single desired object against
+// whatever is currently live in the cluster (or its absence).
+type ComponentDiff struct {
+	// Component identifies the Kind for ClusterFilter-style decisions.
+	Component utils.ComponentType
+	// Kind is the human-readable Kind name used in log messages elsewhere
+	// in the Deployer, e.g. "config map".
+	Kind string
+	// Namespace is empty for cluster-scoped Kinds (e.g. ClusterRole).
+	Namespace string
+	Name      string
+	// Diff is a simplified unified diff between the live object (or
+	// "<absent>" if it doesn't exist yet) and the desired object rendered
+	// from the shorttypes values. Empty means a real deploy would be a
+	// no-op.
+	Diff string
+}
+
+// DiffReport is the result of a DryRun.
+type DiffReport struct {
+	Components []ComponentDiff
+}
+
+// Clean reports whether every component's Diff was empty, i.e. a real
+// deploy would leave the cluster unchanged.
+func (r *DiffReport) Clean() bool {
+	for _, c := range r.Components {
+		if c.Diff != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// DryRun previews what Run would do against the Deployer's own client,
+// without creating, patching or deleting anything, so a caller can gate a
+// real deploy on a clean diff. It does not fan out to a ClusterRegistry's
+// remote clusters; those are only ever touched by Run itself.
+func (d *Deployer) DryRun(mode DryRunMode) (*DiffReport, error) {
+	report := &DiffReport{}
+	allErrs := map[utils.ComponentType][]error{}
+
+	collect := func(ct utils.ComponentType, diffs []ComponentDiff, errs []error) {
+		report.Components = append(report.Components, diffs...)
+		if len(errs) > 0 {
+			allErrs[ct] = errs
+		}
+	}
+
+	diffs, errs := d.diffNamespaces(mode)
+	collect(utils.NamespaceComponent, diffs, errs)
+
+	diffs, errs = d.diffCRDs(mode)
+	collect(utils.CRDComponent, diffs, errs)
+
+	diffs, errs = d.diffServiceAccounts(mode)
+	collect(utils.ServiceAccountComponent, diffs, errs)
+
+	diffs, errs = d.diffClusterRoles(mode)
+	collect(utils.ClusterRoleComponent, diffs, errs)
+
+	diffs, errs = d.diffClusterRoleBindings(mode)
+	collect(utils.ClusterRoleBindingComponent, diffs, errs)
+
+	diffs, errs = d.diffConfigMaps(mode)
+	collect(utils.ConfigMapComponent, diffs, errs)
+
+	diffs, errs = d.diffSecrets(mode)
+	collect(utils.SecretComponent, diffs, errs)
+
+	diffs, errs = d.diffReplicationControllers(mode)
+	collect(utils.ReplicationControllerComponent, diffs, errs)
+
+	diffs, errs = []ComponentDiff{}, []error{}
+	for name, obj := range d.pods {
+		diff, err := d.diffTyped(PodGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	collect(utils.PodComponent, diffs, errs)
+
+	diffs, errs = d.diffDeployments(mode)
+	collect(utils.DeploymentComponent, diffs, errs)
+
+	diffs, errs = d.diffServices(mode)
+	collect(utils.ServiceComponent, diffs, errs)
+
+	customDiffs, customErrs := d.diffCustomResources(mode)
+	report.Components = append(report.Components, customDiffs...)
+	for ct, errs := range customErrs {
+		allErrs[ct] = append(allErrs[ct], errs...)
+	}
+
+	if len(allErrs) > 0 {
+		return report, utilserror.NewDeployErrors(allErrs)
+	}
+	return report, nil
+}
+
+// dryRunTarget names the REST resource a per-kind diff validates against in
+// DryRunServer/DryRunServerApply modes. This vendored client-go predates
+// CreateOptions and PatchOptions, so there's no typed way to ask for a
+// dry run; the query parameter is the only lever available, and it has to
+// go through the generated client's underlying RESTClient() directly.
+type dryRunTarget struct {
+	restClient rest.Interface
+	resource   string
+	namespace  string
+}
+
+// request builds a rest.Request against t's resource, optionally scoped to
+// name, with the dryRun=All query parameter always set.
+func (t dryRunTarget) request(req *rest.Request, name string) *rest.Request {
+	req = req.Resource(t.resource).Param("dryRun", "All")
+	if t.namespace != "" {
+		req = req.Namespace(t.namespace)
+	}
+	if name != "" {
+		req = req.Name(name)
+	}
+	return req
+}
+
+// validate asks the API server to run admission and validation for the
+// create/patch diffObjectWithMode computed, without persisting it.
+func (t dryRunTarget) validate(mode DryRunMode, name string, exists bool, live, desired runtime.Object, modified []byte) error {
+	if mode == DryRunServerApply {
+		return t.request(t.restClient.Patch(k8stypes.ApplyPatchType), name).
+			Param("fieldManager", fieldManager).
+			Body(modified).
+			Do().
+			Error()
+	}
+
+	if !exists {
+		return t.request(t.restClient.Post(), "").Body(desired).Do().Error()
+	}
+
+	current, err := json.Marshal(live)
+	if err != nil {
+		return err
+	}
+	patch, patchType, err := threeWayPatch(lastAppliedAnnotation(live), modified, current, desired)
+	if err != nil {
+		return err
+	}
+	return t.request(t.restClient.Patch(patchType), name).Body(patch).Do().Error()
+}
+
+// diffObjectWithMode mirrors applyObjectWithMode's live-object lookup and
+// last-applied-configuration stamping, but never mutates the cluster: it
+// returns the diff between live (or "<absent>") and desired, validating the
+// create/patch server-side first when mode isn't DryRunClient. Desired is
+// stamped with the annotation the same way ApplyModeUpdate stamps it, so
+// once it has been applied the annotation itself does not show up as a
+// change. The diff covers the whole live object, so fields only the server
+// sets (e.g. resourceVersion, status) show up as removed lines. target may
+// be nil for Kinds that have nothing sensible to validate against (there are none among the built-ins,
+// but a downstream KindHandler could choose to skip server validation).
+func diffObjectWithMode(
+	mode DryRunMode,
+	kind string,
+	componentType utils.ComponentType,
+	namespace, name string,
+	desired runtime.Object,
+	getFn func() (runtime.Object, error),
+	target *dryRunTarget,
+) (ComponentDiff, error) {
+	raw, err := json.Marshal(desired)
+	if err != nil {
+		return ComponentDiff{}, fmt.Errorf("unable to marshal desired %s %s: %v", kind, name, err)
+	}
+	setLastAppliedAnnotation(desired, raw)
+
+	modified, err := json.MarshalIndent(desired, "", "  ")
+	if err != nil {
+		return ComponentDiff{}, fmt.Errorf("unable to marshal desired %s %s with annotation: %v", kind, name, err)
+	}
+
+	live, err := getFn()
+	exists := true
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return ComponentDiff{}, fmt.Errorf("unable to get live %s %s: %v", kind, name, err)
+		}
+		exists = false
+	}
+
+	current := []byte("<absent>")
+	if exists {
+		if current, err = json.MarshalIndent(live, "", "  "); err != nil {
+			return ComponentDiff{}, fmt.Errorf("unable to marshal live %s %s: %v", kind, name, err)
+		}
+	}
+
+	if mode != DryRunClient && target != nil {
+		if err := target.validate(mode, name, exists, live, desired, modified); err != nil {
+			return ComponentDiff{}, fmt.Errorf("server-side validation failed for %s %s: %v", kind, name, err)
+		}
+	}
+
+	return ComponentDiff{
+		Component: componentType,
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		Diff:      unifiedDiff(string(current), string(modified)),
+	}, nil
+}
+
+func (d *Deployer) diffNamespaces(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.namespaces {
+		diff, err := d.diffTyped(NamespaceGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffCRDs(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.crds {
+		diff, err := d.diffTyped(CRDGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffServiceAccounts(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.serviceAccounts {
+		diff, err := d.diffTyped(ServiceAccountGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffClusterRoles(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.clusterRoles {
+		diff, err := d.diffTyped(ClusterRoleGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffClusterRoleBindings(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.clusterRoleBindings {
+		diff, err := d.diffTyped(ClusterRoleBindingGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffConfigMaps(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.configMaps {
+		diff, err := d.diffTyped(ConfigMapGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffSecrets(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.secrets {
+		diff, err := d.diffTyped(SecretGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffReplicationControllers(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.replicationControllers {
+		diff, err := d.diffTyped(ReplicationControllerGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffDeployments(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.deployments {
+		diff, err := d.diffTyped(DeploymentGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+func (d *Deployer) diffServices(mode DryRunMode) ([]ComponentDiff, []error) {
+	diffs := []ComponentDiff{}
+	errs := []error{}
+
+	for name, obj := range d.services {
+		diff, err := d.diffTyped(ServiceGVK, name, obj, mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, errs
+}
+
+// unifiedDiff renders a simplified unified diff (no @@ hunk headers, since
+// DiffReport callers care about what changed, not line numbers) between
+// current and desired, via the longest common subsequence of their lines.
+// An empty result means the two are identical.
+func unifiedDiff(current, desired string) string {
+	if current == desired {
+		return ""
+	}
+
+	a := strings.Split(current, "\n")
+	b := strings.Split(desired, "\n")
+	matches := lcsIndices(a, b)
+
+	var buf strings.Builder
+	i, j := 0, 0
+	for _, m := range matches {
+		for i < m.a {
+			fmt.Fprintf(&buf, "-%s\n", a[i])
+			i++
+		}
+		for j < m.b {
+			fmt.Fprintf(&buf, "+%s\n", b[j])
+			j++
+		}
+		fmt.Fprintf(&buf, " %s\n", a[i])
+		i++
+		j++
+	}
+	for i < len(a) {
+		fmt.Fprintf(&buf, "-%s\n", a[i])
+		i++
+	}
+	for j < len(b) {
+		fmt.Fprintf(&buf, "+%s\n", b[j])
+		j++
+	}
+	return buf.String()
+}
+
+// lcsMatch is a pair of matching line indices into the two slices lcsIndices
+// was given.
+type lcsMatch struct{ a, b int }
+
+// lcsIndices finds the longest common subsequence of equal lines between a
+// and b, via the standard O(len(a)*len(b)) dynamic-programming table, and
+// returns the indices of the matched lines in order.
+func lcsIndices(a, b []string) []lcsMatch {
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				dp[i][j] = dp[i+1][j+1] + 1
+			case dp[i+1][j] >= dp[i][j+1]:
+				dp[i][j] = dp[i+1][j]
+			default:
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]lcsMatch, 0, dp[0][0])
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			matches = append(matches, lcsMatch{i, j})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
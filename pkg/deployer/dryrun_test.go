@@ -0,0 +1,113 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import "testing"
+
+func TestLcsIndices(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []lcsMatch
+	}{
+		{
+			name: "identical",
+			a:    []string{"x", "y", "z"},
+			b:    []string{"x", "y", "z"},
+			want: []lcsMatch{{0, 0}, {1, 1}, {2, 2}},
+		},
+		{
+			name: "no common lines",
+			a:    []string{"a", "b"},
+			b:    []string{"c", "d"},
+			want: []lcsMatch{},
+		},
+		{
+			name: "insertion in the middle",
+			a:    []string{"x", "z"},
+			b:    []string{"x", "y", "z"},
+			want: []lcsMatch{{0, 0}, {1, 2}},
+		},
+		{
+			name: "deletion in the middle",
+			a:    []string{"x", "y", "z"},
+			b:    []string{"x", "z"},
+			want: []lcsMatch{{0, 0}, {2, 1}},
+		},
+		{
+			name: "empty inputs",
+			a:    []string{},
+			b:    []string{},
+			want: []lcsMatch{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lcsIndices(tt.a, tt.b)
+			if len(got) != len(tt.want) {
+				t.Fatalf("lcsIndices(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("lcsIndices(%v, %v)[%d] = %v, want %v", tt.a, tt.b, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired string
+		want             string
+	}{
+		{
+			name:    "identical returns empty",
+			current: "a\nb\nc",
+			desired: "a\nb\nc",
+			want:    "",
+		},
+		{
+			name:    "absent to present",
+			current: "<absent>",
+			desired: "a\nb",
+			want:    "-<absent>\n+a\n+b\n",
+		},
+		{
+			name:    "single line changed",
+			current: "a\nb\nc",
+			desired: "a\nx\nc",
+			want:    " a\n-b\n+x\n c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff(tt.current, tt.desired)
+			if got != tt.want {
+				t.Errorf("unifiedDiff(%q, %q) = %q, want %q", tt.current, tt.desired, got, tt.want)
+			}
+		})
+	}
+}
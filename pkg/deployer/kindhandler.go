@@ -0,0 +1,233 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KindHandler implements deploying a single Kubernetes Kind, so new Kinds
+// can be supported without editing Deployer itself: downstream projects can
+// RegisterKind their own handler, including ones backed by a dynamic client
+// for CRD-typed resources the Deployer knows nothing about.
+type KindHandler interface {
+	// Convert turns a koki/short-typed object (as accepted by the rest of
+	// the Deployer's Add* methods and LoadManifests) into the concrete Kube
+	// API type this handler knows how to deploy.
+	Convert(short interface{}) (runtime.Object, error)
+	// Create deploys obj to cluster unconditionally, failing with
+	// AlreadyExists if it's already present.
+	Create(cluster *Cluster, obj runtime.Object) error
+	// Update makes the live object on cluster match obj, creating it if it
+	// doesn't exist yet. Built-in handlers follow the same create-or-patch,
+	// last-applied-configuration convention as applyObject.
+	Update(cluster *Cluster, obj runtime.Object) error
+	// Delete removes obj from cluster. A NotFound error is not an error.
+	Delete(cluster *Cluster, obj runtime.Object) error
+	// WaitReady blocks until obj is ready on cluster by whatever definition
+	// of "ready" applies to this Kind (e.g. a Deployment's
+	// AvailableReplicas), or returns immediately if the Kind has none.
+	WaitReady(cluster *Cluster, obj runtime.Object) error
+	// Diff compares obj against whatever is live on cluster (or its
+	// absence) for DryRun, without mutating anything. In DryRunServer and
+	// DryRunServerApply modes it additionally asks the API server to
+	// validate the create/patch it would issue, surfacing any admission
+	// failure as an error.
+	Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error)
+	// ComponentType identifies this Kind for error reporting and
+	// ClusterFilter decisions.
+	ComponentType() utils.ComponentType
+	// Tier declares this handler's place in Run's topological deploy order
+	// relative to other handlers: every node whose handler reports a lower
+	// Tier is deployed, and waited on, before this one starts.
+	Tier() int
+}
+
+// RegisterKind attaches handler as the Deployer's implementation for gvk,
+// overriding any built-in handler already registered for it. Objects added
+// via AddCustomResource(gvk, ...) are deployed through handler.
+func (d *Deployer) RegisterKind(gvk schema.GroupVersionKind, handler KindHandler) {
+	if d.kindHandlers == nil {
+		d.kindHandlers = map[schema.GroupVersionKind]KindHandler{}
+	}
+	d.kindHandlers[gvk] = handler
+}
+
+// AddCustomResource registers a koki/short-typed object of a Kind that has
+// a KindHandler registered (built-in or via RegisterKind) to be deployed
+// under name, flowing through the same dependency graph, apply semantics
+// and Undo path as objects added via the Deployer's typed Add* methods.
+func (d *Deployer) AddCustomResource(gvk schema.GroupVersionKind, name string, short interface{}, opts ...AddOption) error {
+	handler, ok := d.kindHandlers[gvk]
+	if !ok {
+		return fmt.Errorf("no KindHandler registered for %s; call RegisterKind first", gvk)
+	}
+
+	if d.customObjects == nil {
+		d.customObjects = map[schema.GroupVersionKind]map[string]interface{}{}
+	}
+	if d.customObjects[gvk] == nil {
+		d.customObjects[gvk] = map[string]interface{}{}
+	}
+	d.customObjects[gvk][name] = short
+
+	d.addNode(name, gvk.Kind, handler.ComponentType(), dagTier(handler.Tier()),
+		func(cd *Deployer) error { return cd.deployCustomResource(gvk, name) },
+		func(cd *Deployer) error { return cd.waitCustomResourceReady(gvk, name) }, opts)
+	return nil
+}
+
+func (d *Deployer) localCluster() *Cluster {
+	return &Cluster{Name: localClusterName, Client: d.client, APIExtensions: d.apiextensions}
+}
+
+// deployTyped converts short via gvk's registered handler and deploys it to
+// the Deployer's own cluster, calling Create in ApplyModeCreateOnly and
+// Update otherwise. It backs every typed Add* method as well as
+// AddCustomResource, so every Kind the Deployer knows about honors ApplyMode
+// the same way.
+func (d *Deployer) deployTyped(gvk schema.GroupVersionKind, name string, short interface{}) error {
+	handler, ok := d.kindHandlers[gvk]
+	if !ok {
+		return fmt.Errorf("no KindHandler registered for %s %s; call RegisterKind first", gvk, name)
+	}
+	obj, err := handler.Convert(short)
+	if err != nil {
+		return fmt.Errorf("unable to convert %s %s: %v", gvk.Kind, name, err)
+	}
+	if d.applyMode == ApplyModeCreateOnly {
+		return handler.Create(d.localCluster(), obj)
+	}
+	return handler.Update(d.localCluster(), obj)
+}
+
+// waitTyped converts short via gvk's registered handler and waits for it to
+// become ready on the Deployer's own cluster. It backs every typed Add*
+// method as well as AddCustomResource.
+func (d *Deployer) waitTyped(gvk schema.GroupVersionKind, name string, short interface{}) error {
+	handler, ok := d.kindHandlers[gvk]
+	if !ok {
+		return fmt.Errorf("no KindHandler registered for %s %s; call RegisterKind first", gvk, name)
+	}
+	obj, err := handler.Convert(short)
+	if err != nil {
+		return fmt.Errorf("unable to convert %s %s: %v", gvk.Kind, name, err)
+	}
+	return handler.WaitReady(d.localCluster(), obj)
+}
+
+// deleteTyped converts short via gvk's registered handler and removes it
+// from the Deployer's own cluster, per Undo's contract. It backs every typed
+// Add* method as well as AddCustomResource.
+func (d *Deployer) deleteTyped(gvk schema.GroupVersionKind, name string, short interface{}) error {
+	handler, ok := d.kindHandlers[gvk]
+	if !ok {
+		return fmt.Errorf("no KindHandler registered for %s %s; call RegisterKind first", gvk, name)
+	}
+	obj, err := handler.Convert(short)
+	if err != nil {
+		return fmt.Errorf("unable to convert %s %s: %v", gvk.Kind, name, err)
+	}
+	return handler.Delete(d.localCluster(), obj)
+}
+
+// diffTyped converts short via gvk's registered handler and computes its
+// DryRun ComponentDiff against the Deployer's own cluster. It backs every
+// typed Add* method as well as AddCustomResource.
+func (d *Deployer) diffTyped(gvk schema.GroupVersionKind, name string, short interface{}, mode DryRunMode) (ComponentDiff, error) {
+	handler, ok := d.kindHandlers[gvk]
+	if !ok {
+		return ComponentDiff{}, fmt.Errorf("no KindHandler registered for %s %s; call RegisterKind first", gvk, name)
+	}
+	obj, err := handler.Convert(short)
+	if err != nil {
+		return ComponentDiff{}, fmt.Errorf("unable to convert %s %s: %v", gvk.Kind, name, err)
+	}
+	diff, err := handler.Diff(d.localCluster(), obj, mode)
+	if err != nil {
+		return ComponentDiff{}, err
+	}
+	return *diff, nil
+}
+
+func (d *Deployer) deployCustomResource(gvk schema.GroupVersionKind, name string) error {
+	short, ok := d.customObjects[gvk][name]
+	if !ok {
+		return nil
+	}
+	return d.deployTyped(gvk, name, short)
+}
+
+func (d *Deployer) waitCustomResourceReady(gvk schema.GroupVersionKind, name string) error {
+	short, ok := d.customObjects[gvk][name]
+	if !ok {
+		return nil
+	}
+	return d.waitTyped(gvk, name, short)
+}
+
+// diffCustomResources computes a DryRun ComponentDiff for every object added
+// via AddCustomResource, through its handler's Diff method.
+func (d *Deployer) diffCustomResources(mode DryRunMode) ([]ComponentDiff, map[utils.ComponentType][]error) {
+	diffs := []ComponentDiff{}
+	errs := map[utils.ComponentType][]error{}
+
+	for gvk, objects := range d.customObjects {
+		handler, ok := d.kindHandlers[gvk]
+		if !ok {
+			continue
+		}
+		for name, short := range objects {
+			diff, err := d.diffTyped(gvk, name, short, mode)
+			if err != nil {
+				errs[handler.ComponentType()] = append(errs[handler.ComponentType()], err)
+				continue
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, errs
+}
+
+// undeployCustomResources removes every object added via AddCustomResource,
+// through its handler's Delete method, per Undo's contract.
+func (d *Deployer) undeployCustomResources() map[utils.ComponentType][]error {
+	errs := map[utils.ComponentType][]error{}
+
+	for gvk, objects := range d.customObjects {
+		handler, ok := d.kindHandlers[gvk]
+		if !ok {
+			continue
+		}
+		for name, short := range objects {
+			if err := d.deleteTyped(gvk, name, short); err != nil {
+				errs[handler.ComponentType()] = append(errs[handler.ComponentType()], err)
+			}
+		}
+	}
+	return errs
+}
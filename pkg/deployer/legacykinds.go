@@ -0,0 +1,709 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/utils"
+
+	"github.com/koki/short/converter/converters"
+	shorttypes "github.com/koki/short/types"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// GroupVersionKinds for the original ten Kinds the Deployer has always
+// shipped typed Add* methods for, plus Pod, which is added via AddPod and
+// has a WaitReady step of its own. They can all be looked up in
+// kindHandlers the same way as the Kinds registered via
+// RegisterKind/AddCustomResource.
+var (
+	NamespaceGVK             = schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	CRDGVK                   = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}
+	ServiceAccountGVK        = schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}
+	ClusterRoleGVK           = schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+	ClusterRoleBindingGVK    = schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRoleBinding"}
+	ConfigMapGVK             = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	SecretGVK                = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	ReplicationControllerGVK = schema.GroupVersionKind{Version: "v1", Kind: "ReplicationController"}
+	DeploymentGVK            = schema.GroupVersionKind{Group: "apps", Version: "v1beta2", Kind: "Deployment"}
+	ServiceGVK               = schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+	PodGVK                   = schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+)
+
+// registerLegacyKindHandlers wires up KindHandlers for the original ten
+// Kinds plus Pod, so RegisterKind can override any of them just like the
+// eight added later via AddCustomResource, and so every Kind the Deployer
+// knows about flows through the same handler-driven deploy/wait/diff/delete
+// dispatch.
+func registerLegacyKindHandlers(d *Deployer) {
+	d.RegisterKind(NamespaceGVK, namespaceHandler{})
+	d.RegisterKind(CRDGVK, crdHandler{})
+	d.RegisterKind(ServiceAccountGVK, serviceAccountHandler{})
+	d.RegisterKind(ClusterRoleGVK, clusterRoleHandler{})
+	d.RegisterKind(ClusterRoleBindingGVK, clusterRoleBindingHandler{})
+	d.RegisterKind(ConfigMapGVK, configMapHandler{})
+	d.RegisterKind(SecretGVK, secretHandler{})
+	d.RegisterKind(ReplicationControllerGVK, replicationControllerHandler{})
+	d.RegisterKind(DeploymentGVK, deploymentHandler{})
+	d.RegisterKind(ServiceGVK, serviceHandler{})
+	d.RegisterKind(PodGVK, podHandler{})
+}
+
+type namespaceHandler struct{}
+
+func (namespaceHandler) ComponentType() utils.ComponentType { return utils.NamespaceComponent }
+func (namespaceHandler) Tier() int                          { return int(tierNamespace) }
+
+func (namespaceHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.Namespace, got %T", short)
+	}
+	return converters.Convert_Koki_Namespace_to_Kube_Namespace(&shorttypes.NamespaceWrapper{Namespace: *obj})
+}
+
+func (namespaceHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	ns := obj.(*v1.Namespace)
+	_, err := cluster.Client.Core().Namespaces().Create(ns)
+	return err
+}
+
+func (namespaceHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	ns := obj.(*v1.Namespace)
+	namespaces := cluster.Client.Core().Namespaces()
+	return applyObjectWithMode(ApplyModeUpdate, "namespace", ns.Name, ns,
+		func() (runtime.Object, error) { return namespaces.Get(ns.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := namespaces.Create(o.(*v1.Namespace)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := namespaces.Patch(ns.Name, pt, patch); return err },
+	)
+}
+
+func (namespaceHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	ns := obj.(*v1.Namespace)
+	namespaces := cluster.Client.Core().Namespaces()
+	return deleteManagedObject("namespace", ns.Name,
+		func() (runtime.Object, error) { return namespaces.Get(ns.Name, metav1.GetOptions{}) },
+		func() error { return namespaces.Delete(ns.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady blocks until the Namespace's phase is Active.
+func (namespaceHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	ns := obj.(*v1.Namespace)
+	namespaces := cluster.Client.Core().Namespaces()
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := namespaces.Get(ns.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return live.Status.Phase == v1.NamespaceActive, nil
+	})
+}
+
+func (h namespaceHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	ns := obj.(*v1.Namespace)
+	namespaces := cluster.Client.Core().Namespaces()
+	target := &dryRunTarget{restClient: cluster.Client.Core().RESTClient(), resource: "namespaces"}
+	diff, err := diffObjectWithMode(mode, "namespace", h.ComponentType(), "", ns.Name, ns,
+		func() (runtime.Object, error) { return namespaces.Get(ns.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type crdHandler struct{}
+
+func (crdHandler) ComponentType() utils.ComponentType { return utils.CRDComponent }
+func (crdHandler) Tier() int                          { return int(tierCRD) }
+
+func (crdHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.CustomResourceDefinition)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.CustomResourceDefinition, got %T", short)
+	}
+	return converters.Convert_Koki_CRD_to_Kube(&shorttypes.CRDWrapper{CRD: *obj})
+}
+
+func (crdHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	crd := obj.(*apiextensionsv1beta1.CustomResourceDefinition)
+	_, err := cluster.APIExtensions.ApiextensionsV1beta1().CustomResourceDefinitions().Create(crd)
+	return err
+}
+
+func (crdHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	crd := obj.(*apiextensionsv1beta1.CustomResourceDefinition)
+	crds := cluster.APIExtensions.ApiextensionsV1beta1().CustomResourceDefinitions()
+	return applyObjectWithMode(ApplyModeUpdate, "custom resource definition", crd.Name, crd,
+		func() (runtime.Object, error) { return crds.Get(crd.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := crds.Create(o.(*apiextensionsv1beta1.CustomResourceDefinition)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := crds.Patch(crd.Name, pt, patch); return err },
+	)
+}
+
+func (crdHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	crd := obj.(*apiextensionsv1beta1.CustomResourceDefinition)
+	crds := cluster.APIExtensions.ApiextensionsV1beta1().CustomResourceDefinitions()
+	return deleteManagedObject("custom resource definition", crd.Name,
+		func() (runtime.Object, error) { return crds.Get(crd.Name, metav1.GetOptions{}) },
+		func() error { return crds.Delete(crd.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady blocks until the CustomResourceDefinition reports an Established
+// condition of True, so dependents can safely create CRs.
+func (crdHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	crd := obj.(*apiextensionsv1beta1.CustomResourceDefinition)
+	crds := cluster.APIExtensions.ApiextensionsV1beta1().CustomResourceDefinitions()
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := crds.Get(crd.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, cond := range live.Status.Conditions {
+			if cond.Type == apiextensionsv1beta1.Established && cond.Status == apiextensionsv1beta1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func (h crdHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	crd := obj.(*apiextensionsv1beta1.CustomResourceDefinition)
+	crds := cluster.APIExtensions.ApiextensionsV1beta1().CustomResourceDefinitions()
+	target := &dryRunTarget{restClient: cluster.APIExtensions.ApiextensionsV1beta1().RESTClient(), resource: "customresourcedefinitions"}
+	diff, err := diffObjectWithMode(mode, "custom resource definition", h.ComponentType(), "", crd.Name, crd,
+		func() (runtime.Object, error) { return crds.Get(crd.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type serviceAccountHandler struct{}
+
+func (serviceAccountHandler) ComponentType() utils.ComponentType { return utils.ServiceAccountComponent }
+func (serviceAccountHandler) Tier() int                          { return int(tierNamedConfig) }
+
+func (serviceAccountHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.ServiceAccount)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.ServiceAccount, got %T", short)
+	}
+	return converters.Convert_Koki_ServiceAccount_to_Kube_ServiceAccount(&shorttypes.ServiceAccountWrapper{ServiceAccount: *obj})
+}
+
+func (serviceAccountHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	sa := obj.(*v1.ServiceAccount)
+	_, err := cluster.Client.Core().ServiceAccounts(sa.Namespace).Create(sa)
+	return err
+}
+
+func (serviceAccountHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	sa := obj.(*v1.ServiceAccount)
+	serviceAccounts := cluster.Client.Core().ServiceAccounts(sa.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "service account", sa.Name, sa,
+		func() (runtime.Object, error) { return serviceAccounts.Get(sa.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := serviceAccounts.Create(o.(*v1.ServiceAccount)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := serviceAccounts.Patch(sa.Name, pt, patch); return err },
+	)
+}
+
+func (serviceAccountHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	sa := obj.(*v1.ServiceAccount)
+	serviceAccounts := cluster.Client.Core().ServiceAccounts(sa.Namespace)
+	return deleteManagedObject("service account", sa.Name,
+		func() (runtime.Object, error) { return serviceAccounts.Get(sa.Name, metav1.GetOptions{}) },
+		func() error { return serviceAccounts.Delete(sa.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op: a ServiceAccount is ready as soon as it's created.
+func (serviceAccountHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h serviceAccountHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	sa := obj.(*v1.ServiceAccount)
+	serviceAccounts := cluster.Client.Core().ServiceAccounts(sa.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.Core().RESTClient(), resource: "serviceaccounts", namespace: sa.Namespace}
+	diff, err := diffObjectWithMode(mode, "service account", h.ComponentType(), sa.Namespace, sa.Name, sa,
+		func() (runtime.Object, error) { return serviceAccounts.Get(sa.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type clusterRoleHandler struct{}
+
+func (clusterRoleHandler) ComponentType() utils.ComponentType { return utils.ClusterRoleComponent }
+func (clusterRoleHandler) Tier() int                          { return int(tierNamedConfig) }
+
+func (clusterRoleHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.ClusterRole)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.ClusterRole, got %T", short)
+	}
+	return converters.Convert_Koki_ClusterRole_to_Kube(&shorttypes.ClusterRoleWrapper{ClusterRole: *obj})
+}
+
+func (clusterRoleHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	cr := obj.(*rbacv1.ClusterRole)
+	_, err := cluster.Client.Rbac().ClusterRoles().Create(cr)
+	return err
+}
+
+func (clusterRoleHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	cr := obj.(*rbacv1.ClusterRole)
+	clusterRoles := cluster.Client.Rbac().ClusterRoles()
+	return applyObjectWithMode(ApplyModeUpdate, "cluster role", cr.Name, cr,
+		func() (runtime.Object, error) { return clusterRoles.Get(cr.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := clusterRoles.Create(o.(*rbacv1.ClusterRole)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := clusterRoles.Patch(cr.Name, pt, patch); return err },
+	)
+}
+
+func (clusterRoleHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	cr := obj.(*rbacv1.ClusterRole)
+	clusterRoles := cluster.Client.Rbac().ClusterRoles()
+	return deleteManagedObject("cluster role", cr.Name,
+		func() (runtime.Object, error) { return clusterRoles.Get(cr.Name, metav1.GetOptions{}) },
+		func() error { return clusterRoles.Delete(cr.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op: a ClusterRole is ready as soon as it's created.
+func (clusterRoleHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h clusterRoleHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	cr := obj.(*rbacv1.ClusterRole)
+	clusterRoles := cluster.Client.Rbac().ClusterRoles()
+	target := &dryRunTarget{restClient: cluster.Client.Rbac().RESTClient(), resource: "clusterroles"}
+	diff, err := diffObjectWithMode(mode, "cluster role", h.ComponentType(), "", cr.Name, cr,
+		func() (runtime.Object, error) { return clusterRoles.Get(cr.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type clusterRoleBindingHandler struct{}
+
+func (clusterRoleBindingHandler) ComponentType() utils.ComponentType {
+	return utils.ClusterRoleBindingComponent
+}
+func (clusterRoleBindingHandler) Tier() int { return int(tierNamedConfig) }
+
+func (clusterRoleBindingHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.ClusterRoleBinding)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.ClusterRoleBinding, got %T", short)
+	}
+	return converters.Convert_Koki_ClusterRoleBinding_to_Kube(&shorttypes.ClusterRoleBindingWrapper{ClusterRoleBinding: *obj})
+}
+
+func (clusterRoleBindingHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	crb := obj.(*rbacv1.ClusterRoleBinding)
+	_, err := cluster.Client.Rbac().ClusterRoleBindings().Create(crb)
+	return err
+}
+
+func (clusterRoleBindingHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	crb := obj.(*rbacv1.ClusterRoleBinding)
+	clusterRoleBindings := cluster.Client.Rbac().ClusterRoleBindings()
+	return applyObjectWithMode(ApplyModeUpdate, "cluster role binding", crb.Name, crb,
+		func() (runtime.Object, error) { return clusterRoleBindings.Get(crb.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := clusterRoleBindings.Create(o.(*rbacv1.ClusterRoleBinding)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := clusterRoleBindings.Patch(crb.Name, pt, patch); return err },
+	)
+}
+
+func (clusterRoleBindingHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	crb := obj.(*rbacv1.ClusterRoleBinding)
+	clusterRoleBindings := cluster.Client.Rbac().ClusterRoleBindings()
+	return deleteManagedObject("cluster role binding", crb.Name,
+		func() (runtime.Object, error) { return clusterRoleBindings.Get(crb.Name, metav1.GetOptions{}) },
+		func() error { return clusterRoleBindings.Delete(crb.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op: a ClusterRoleBinding is ready as soon as it's
+// created.
+func (clusterRoleBindingHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h clusterRoleBindingHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	crb := obj.(*rbacv1.ClusterRoleBinding)
+	clusterRoleBindings := cluster.Client.Rbac().ClusterRoleBindings()
+	target := &dryRunTarget{restClient: cluster.Client.Rbac().RESTClient(), resource: "clusterrolebindings"}
+	diff, err := diffObjectWithMode(mode, "cluster role binding", h.ComponentType(), "", crb.Name, crb,
+		func() (runtime.Object, error) { return clusterRoleBindings.Get(crb.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type configMapHandler struct{}
+
+func (configMapHandler) ComponentType() utils.ComponentType { return utils.ConfigMapComponent }
+func (configMapHandler) Tier() int                          { return int(tierNamedConfig) }
+
+func (configMapHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.ConfigMap)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.ConfigMap, got %T", short)
+	}
+	return converters.Convert_Koki_ConfigMap_to_Kube_v1_ConfigMap(&shorttypes.ConfigMapWrapper{ConfigMap: *obj})
+}
+
+func (configMapHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	cm := obj.(*v1.ConfigMap)
+	_, err := cluster.Client.Core().ConfigMaps(cm.Namespace).Create(cm)
+	return err
+}
+
+func (configMapHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	cm := obj.(*v1.ConfigMap)
+	configMaps := cluster.Client.Core().ConfigMaps(cm.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "config map", cm.Name, cm,
+		func() (runtime.Object, error) { return configMaps.Get(cm.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := configMaps.Create(o.(*v1.ConfigMap)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := configMaps.Patch(cm.Name, pt, patch); return err },
+	)
+}
+
+func (configMapHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	cm := obj.(*v1.ConfigMap)
+	configMaps := cluster.Client.Core().ConfigMaps(cm.Namespace)
+	return deleteManagedObject("config map", cm.Name,
+		func() (runtime.Object, error) { return configMaps.Get(cm.Name, metav1.GetOptions{}) },
+		func() error { return configMaps.Delete(cm.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op: a ConfigMap is ready as soon as it's created.
+func (configMapHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h configMapHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	cm := obj.(*v1.ConfigMap)
+	configMaps := cluster.Client.Core().ConfigMaps(cm.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.Core().RESTClient(), resource: "configmaps", namespace: cm.Namespace}
+	diff, err := diffObjectWithMode(mode, "config map", h.ComponentType(), cm.Namespace, cm.Name, cm,
+		func() (runtime.Object, error) { return configMaps.Get(cm.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type secretHandler struct{}
+
+func (secretHandler) ComponentType() utils.ComponentType { return utils.SecretComponent }
+func (secretHandler) Tier() int                          { return int(tierNamedConfig) }
+
+func (secretHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.Secret)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.Secret, got %T", short)
+	}
+	return converters.Convert_Koki_Secret_to_Kube_v1_Secret(&shorttypes.SecretWrapper{Secret: *obj})
+}
+
+func (secretHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	secret := obj.(*v1.Secret)
+	_, err := cluster.Client.Core().Secrets(secret.Namespace).Create(secret)
+	return err
+}
+
+func (secretHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	secret := obj.(*v1.Secret)
+	secrets := cluster.Client.Core().Secrets(secret.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "secret", secret.Name, secret,
+		func() (runtime.Object, error) { return secrets.Get(secret.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := secrets.Create(o.(*v1.Secret)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := secrets.Patch(secret.Name, pt, patch); return err },
+	)
+}
+
+func (secretHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	secret := obj.(*v1.Secret)
+	secrets := cluster.Client.Core().Secrets(secret.Namespace)
+	return deleteManagedObject("secret", secret.Name,
+		func() (runtime.Object, error) { return secrets.Get(secret.Name, metav1.GetOptions{}) },
+		func() error { return secrets.Delete(secret.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op: a Secret is ready as soon as it's created.
+func (secretHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h secretHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	secret := obj.(*v1.Secret)
+	secrets := cluster.Client.Core().Secrets(secret.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.Core().RESTClient(), resource: "secrets", namespace: secret.Namespace}
+	diff, err := diffObjectWithMode(mode, "secret", h.ComponentType(), secret.Namespace, secret.Name, secret,
+		func() (runtime.Object, error) { return secrets.Get(secret.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type replicationControllerHandler struct{}
+
+func (replicationControllerHandler) ComponentType() utils.ComponentType {
+	return utils.ReplicationControllerComponent
+}
+func (replicationControllerHandler) Tier() int { return int(tierWorkload) }
+
+func (replicationControllerHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.ReplicationController)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.ReplicationController, got %T", short)
+	}
+	return converters.Convert_Koki_ReplicationController_to_Kube_v1_ReplicationController(&shorttypes.ReplicationControllerWrapper{ReplicationController: *obj})
+}
+
+func (replicationControllerHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	rc := obj.(*v1.ReplicationController)
+	_, err := cluster.Client.Core().ReplicationControllers(rc.Namespace).Create(rc)
+	return err
+}
+
+func (replicationControllerHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	rc := obj.(*v1.ReplicationController)
+	replicationControllers := cluster.Client.Core().ReplicationControllers(rc.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "replication controller", rc.Name, rc,
+		func() (runtime.Object, error) { return replicationControllers.Get(rc.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := replicationControllers.Create(o.(*v1.ReplicationController)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := replicationControllers.Patch(rc.Name, pt, patch); return err },
+	)
+}
+
+func (replicationControllerHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	rc := obj.(*v1.ReplicationController)
+	replicationControllers := cluster.Client.Core().ReplicationControllers(rc.Namespace)
+	return deleteManagedObject("replication controller", rc.Name,
+		func() (runtime.Object, error) { return replicationControllers.Get(rc.Name, metav1.GetOptions{}) },
+		func() error { return replicationControllers.Delete(rc.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady is a no-op, matching the Deployer's historical behavior of not
+// waiting on ReplicationControllers.
+func (replicationControllerHandler) WaitReady(cluster *Cluster, obj runtime.Object) error { return nil }
+
+func (h replicationControllerHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	rc := obj.(*v1.ReplicationController)
+	replicationControllers := cluster.Client.Core().ReplicationControllers(rc.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.Core().RESTClient(), resource: "replicationcontrollers", namespace: rc.Namespace}
+	diff, err := diffObjectWithMode(mode, "replication controller", h.ComponentType(), rc.Namespace, rc.Name, rc,
+		func() (runtime.Object, error) { return replicationControllers.Get(rc.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type deploymentHandler struct{}
+
+func (deploymentHandler) ComponentType() utils.ComponentType { return utils.DeploymentComponent }
+func (deploymentHandler) Tier() int                          { return int(tierWorkload) }
+
+func (deploymentHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.Deployment)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.Deployment, got %T", short)
+	}
+	return converters.Convert_Koki_Deployment_to_Kube_apps_v1beta2_Deployment(&shorttypes.DeploymentWrapper{Deployment: *obj})
+}
+
+func (deploymentHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	deploy := obj.(*appsv1beta2.Deployment)
+	_, err := cluster.Client.AppsV1beta2().Deployments(deploy.Namespace).Create(deploy)
+	return err
+}
+
+func (deploymentHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	deploy := obj.(*appsv1beta2.Deployment)
+	deployments := cluster.Client.AppsV1beta2().Deployments(deploy.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "deployment", deploy.Name, deploy,
+		func() (runtime.Object, error) { return deployments.Get(deploy.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := deployments.Create(o.(*appsv1beta2.Deployment)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := deployments.Patch(deploy.Name, pt, patch); return err },
+	)
+}
+
+func (deploymentHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	deploy := obj.(*appsv1beta2.Deployment)
+	deployments := cluster.Client.AppsV1beta2().Deployments(deploy.Namespace)
+	return deleteManagedObject("deployment", deploy.Name,
+		func() (runtime.Object, error) { return deployments.Get(deploy.Name, metav1.GetOptions{}) },
+		func() error { return deployments.Delete(deploy.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady blocks until the Deployment's AvailableReplicas has caught up
+// with its desired Replicas.
+func (deploymentHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	deploy := obj.(*appsv1beta2.Deployment)
+	deployments := cluster.Client.AppsV1beta2().Deployments(deploy.Namespace)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := deployments.Get(deploy.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		desired := int32(1)
+		if live.Spec.Replicas != nil {
+			desired = *live.Spec.Replicas
+		}
+		return live.Status.AvailableReplicas >= desired, nil
+	})
+}
+
+func (h deploymentHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	deploy := obj.(*appsv1beta2.Deployment)
+	deployments := cluster.Client.AppsV1beta2().Deployments(deploy.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.AppsV1beta2().RESTClient(), resource: "deployments", namespace: deploy.Namespace}
+	diff, err := diffObjectWithMode(mode, "deployment", h.ComponentType(), deploy.Namespace, deploy.Name, deploy,
+		func() (runtime.Object, error) { return deployments.Get(deploy.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type serviceHandler struct{}
+
+func (serviceHandler) ComponentType() utils.ComponentType { return utils.ServiceComponent }
+func (serviceHandler) Tier() int                          { return int(tierService) }
+
+func (serviceHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.Service, got %T", short)
+	}
+	return converters.Convert_Koki_Service_To_Kube_v1_Service(&shorttypes.ServiceWrapper{Service: *obj})
+}
+
+func (serviceHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	svc := obj.(*v1.Service)
+	_, err := cluster.Client.Core().Services(svc.Namespace).Create(svc)
+	return err
+}
+
+func (serviceHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	svc := obj.(*v1.Service)
+	services := cluster.Client.Core().Services(svc.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "service", svc.Name, svc,
+		func() (runtime.Object, error) { return services.Get(svc.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := services.Create(o.(*v1.Service)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := services.Patch(svc.Name, pt, patch); return err },
+	)
+}
+
+func (serviceHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	svc := obj.(*v1.Service)
+	services := cluster.Client.Core().Services(svc.Namespace)
+	return deleteManagedObject("service", svc.Name,
+		func() (runtime.Object, error) { return services.Get(svc.Name, metav1.GetOptions{}) },
+		func() error { return services.Delete(svc.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady blocks until the Service has at least one populated Endpoints
+// subset, meaning something behind it is routable.
+func (serviceHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	svc := obj.(*v1.Service)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		endpoints, err := cluster.Client.Core().Endpoints(svc.Namespace).Get(svc.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, subset := range endpoints.Subsets {
+			if len(subset.Addresses) > 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func (h serviceHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	svc := obj.(*v1.Service)
+	services := cluster.Client.Core().Services(svc.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.Core().RESTClient(), resource: "services", namespace: svc.Namespace}
+	diff, err := diffObjectWithMode(mode, "service", h.ComponentType(), svc.Namespace, svc.Name, svc,
+		func() (runtime.Object, error) { return services.Get(svc.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
+
+type podHandler struct{}
+
+func (podHandler) ComponentType() utils.ComponentType { return utils.PodComponent }
+func (podHandler) Tier() int                          { return int(tierWorkload) }
+
+func (podHandler) Convert(short interface{}) (runtime.Object, error) {
+	obj, ok := short.(*shorttypes.Pod)
+	if !ok {
+		return nil, fmt.Errorf("expected *shorttypes.Pod, got %T", short)
+	}
+	return converters.Convert_Koki_Pod_to_Kube_v1_Pod(&shorttypes.PodWrapper{Pod: *obj})
+}
+
+func (podHandler) Create(cluster *Cluster, obj runtime.Object) error {
+	pod := obj.(*v1.Pod)
+	_, err := cluster.Client.Core().Pods(pod.Namespace).Create(pod)
+	return err
+}
+
+func (podHandler) Update(cluster *Cluster, obj runtime.Object) error {
+	pod := obj.(*v1.Pod)
+	pods := cluster.Client.Core().Pods(pod.Namespace)
+	return applyObjectWithMode(ApplyModeUpdate, "pod", pod.Name, pod,
+		func() (runtime.Object, error) { return pods.Get(pod.Name, metav1.GetOptions{}) },
+		func(o runtime.Object) error { _, err := pods.Create(o.(*v1.Pod)); return err },
+		func(pt k8stypes.PatchType, patch []byte) error { _, err := pods.Patch(pod.Name, pt, patch); return err },
+	)
+}
+
+func (podHandler) Delete(cluster *Cluster, obj runtime.Object) error {
+	pod := obj.(*v1.Pod)
+	pods := cluster.Client.Core().Pods(pod.Namespace)
+	return deleteManagedObject("pod", pod.Name,
+		func() (runtime.Object, error) { return pods.Get(pod.Name, metav1.GetOptions{}) },
+		func() error { return pods.Delete(pod.Name, &metav1.DeleteOptions{}) },
+	)
+}
+
+// WaitReady blocks until the Pod has reached phase Running and its PodReady
+// condition is True.
+func (podHandler) WaitReady(cluster *Cluster, obj runtime.Object) error {
+	pod := obj.(*v1.Pod)
+	pods := cluster.Client.Core().Pods(pod.Namespace)
+	return waitForReady(defaultWaitReadyTimeout, func() (bool, error) {
+		live, err := pods.Get(pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if live.Status.Phase != v1.PodRunning {
+			return false, nil
+		}
+		for _, cond := range live.Status.Conditions {
+			if cond.Type == v1.PodReady && cond.Status == v1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func (h podHandler) Diff(cluster *Cluster, obj runtime.Object, mode DryRunMode) (*ComponentDiff, error) {
+	pod := obj.(*v1.Pod)
+	pods := cluster.Client.Core().Pods(pod.Namespace)
+	target := &dryRunTarget{restClient: cluster.Client.Core().RESTClient(), resource: "pods", namespace: pod.Namespace}
+	diff, err := diffObjectWithMode(mode, "pod", h.ComponentType(), pod.Namespace, pod.Name, pod,
+		func() (runtime.Object, error) { return pods.Get(pod.Name, metav1.GetOptions{}) }, target)
+	return &diff, err
+}
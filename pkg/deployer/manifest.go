@@ -0,0 +1,430 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/blackducksoftware/cn-crd-controller/pkg/types"
+
+	"github.com/ghodss/yaml"
+	"github.com/koki/short/converter/converters"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// LoadManifests parses a multi-document YAML or JSON stream and adds each
+// document to the Deployer via the same Add* path a caller would use
+// programmatically, so manifests flow through the same deploy, dependency
+// graph and Undo machinery as hand-built objects. Each document is
+// dispatched by its kind alone (its apiVersion is not checked); documents
+// of a kind the Deployer doesn't support return an error identifying the offending document.
+func (d *Deployer) LoadManifests(r io.Reader) error {
+	decoder := k8syaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("unable to decode manifest document: %v", err)
+		}
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		if err := d.loadManifestDoc(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// LoadDir loads every .yaml, .yml and .json file directly under dir (non-
+// recursively) via LoadManifests, in lexical order. Files named values.yaml
+// or Chart.yaml are skipped, since those describe a Helm chart rather than
+// a raw manifest; use LoadChart to render and load a chart directory.
+func (d *Deployer) LoadDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read manifest directory %s: %v", dir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			continue
+		}
+		if name == "values.yaml" || name == "Chart.yaml" {
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("unable to open manifest %s: %v", name, err)
+		}
+		err = d.LoadManifests(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to load manifest %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// loadManifestDoc dispatches a single decoded document to the Add* method
+// matching its GroupVersionKind, converting the Kube-native object back
+// into the koki/short type the rest of the Deployer works with.
+func (d *Deployer) loadManifestDoc(raw []byte) error {
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(raw, &meta); err != nil {
+		return fmt.Errorf("unable to read apiVersion/kind: %v", err)
+	}
+
+	switch meta.Kind {
+	case "Namespace":
+		var obj v1.Namespace
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal Namespace: %v", err)
+		}
+		koki, err := converters.Convert_Kube_Namespace_to_Koki_Namespace(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert Namespace %s: %v", obj.Name, err)
+		}
+		d.AddNamespace(types.NewNamespace(koki))
+	case "CustomResourceDefinition":
+		var obj apiextensionsv1beta1.CustomResourceDefinition
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal CustomResourceDefinition: %v", err)
+		}
+		koki, err := converters.Convert_Kube_CRD_to_Koki(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert CustomResourceDefinition %s: %v", obj.Name, err)
+		}
+		d.AddCustomDefinedResource(types.NewCustomResourceDefinition(koki))
+	case "ServiceAccount":
+		var obj v1.ServiceAccount
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal ServiceAccount: %v", err)
+		}
+		koki, err := converters.Convert_Kube_ServiceAccount_to_Koki_ServiceAccount(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert ServiceAccount %s: %v", obj.Name, err)
+		}
+		d.AddServiceAccount(types.NewServiceAccount(koki))
+	case "ClusterRole":
+		var obj rbacv1.ClusterRole
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal ClusterRole: %v", err)
+		}
+		koki, err := converters.Convert_Kube_ClusterRole_to_Koki(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert ClusterRole %s: %v", obj.Name, err)
+		}
+		d.AddClusterRole(types.NewClusterRole(koki))
+	case "ClusterRoleBinding":
+		var obj rbacv1.ClusterRoleBinding
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal ClusterRoleBinding: %v", err)
+		}
+		koki, err := converters.Convert_Kube_ClusterRoleBinding_to_Koki(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert ClusterRoleBinding %s: %v", obj.Name, err)
+		}
+		d.AddClusterRoleBinding(types.NewClusterRoleBinding(koki))
+	case "ConfigMap":
+		var obj v1.ConfigMap
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal ConfigMap: %v", err)
+		}
+		koki, err := converters.Convert_Kube_v1_ConfigMap_to_Koki_ConfigMap(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert ConfigMap %s: %v", obj.Name, err)
+		}
+		d.AddConfigMap(types.NewConfigMap(koki))
+	case "Secret":
+		var obj v1.Secret
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal Secret: %v", err)
+		}
+		koki, err := converters.Convert_Kube_v1_Secret_to_Koki_Secret(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert Secret %s: %v", obj.Name, err)
+		}
+		d.AddSecret(types.NewSecret(koki))
+	case "ReplicationController":
+		var obj v1.ReplicationController
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal ReplicationController: %v", err)
+		}
+		koki, err := converters.Convert_Kube_v1_ReplicationController_to_Koki_ReplicationController(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert ReplicationController %s: %v", obj.Name, err)
+		}
+		d.AddReplicationConroller(types.NewReplicationController(koki))
+	case "Deployment":
+		var obj appsv1beta2.Deployment
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal Deployment: %v", err)
+		}
+		koki, err := converters.Convert_Kube_apps_v1beta2_Deployment_to_Koki_Deployment(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert Deployment %s: %v", obj.Name, err)
+		}
+		d.AddDeployment(types.NewDeployment(koki))
+	case "Service":
+		var obj v1.Service
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal Service: %v", err)
+		}
+		koki, err := converters.Convert_Kube_v1_Service_To_Koki_Service(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert Service %s: %v", obj.Name, err)
+		}
+		d.AddService(types.NewService(koki))
+	case "Job":
+		var obj batchv1.Job
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal Job: %v", err)
+		}
+		koki, err := converters.Convert_Kube_batch_v1_Job_to_Koki_Job(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert Job %s: %v", obj.Name, err)
+		}
+		if err := d.AddJob(types.NewJob(koki)); err != nil {
+			return fmt.Errorf("unable to add Job %s: %v", obj.Name, err)
+		}
+	case "CronJob":
+		var obj batchv1beta1.CronJob
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal CronJob: %v", err)
+		}
+		koki, err := converters.Convert_Kube_batch_v1beta1_CronJob_to_Koki_CronJob(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert CronJob %s: %v", obj.Name, err)
+		}
+		if err := d.AddCronJob(types.NewCronJob(koki)); err != nil {
+			return fmt.Errorf("unable to add CronJob %s: %v", obj.Name, err)
+		}
+	case "StatefulSet":
+		var obj appsv1beta2.StatefulSet
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal StatefulSet: %v", err)
+		}
+		koki, err := converters.Convert_Kube_apps_v1beta2_StatefulSet_to_Koki_StatefulSet(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert StatefulSet %s: %v", obj.Name, err)
+		}
+		if err := d.AddStatefulSet(types.NewStatefulSet(koki)); err != nil {
+			return fmt.Errorf("unable to add StatefulSet %s: %v", obj.Name, err)
+		}
+	case "DaemonSet":
+		var obj appsv1beta2.DaemonSet
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal DaemonSet: %v", err)
+		}
+		koki, err := converters.Convert_Kube_apps_v1beta2_DaemonSet_to_Koki_DaemonSet(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert DaemonSet %s: %v", obj.Name, err)
+		}
+		if err := d.AddDaemonSet(types.NewDaemonSet(koki)); err != nil {
+			return fmt.Errorf("unable to add DaemonSet %s: %v", obj.Name, err)
+		}
+	case "Ingress":
+		var obj extensionsv1beta1.Ingress
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal Ingress: %v", err)
+		}
+		koki, err := converters.Convert_Kube_extensions_v1beta1_Ingress_to_Koki_Ingress(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert Ingress %s: %v", obj.Name, err)
+		}
+		if err := d.AddIngress(types.NewIngress(koki)); err != nil {
+			return fmt.Errorf("unable to add Ingress %s: %v", obj.Name, err)
+		}
+	case "NetworkPolicy":
+		var obj extensionsv1beta1.NetworkPolicy
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal NetworkPolicy: %v", err)
+		}
+		koki, err := converters.Convert_Kube_extensions_v1beta1_NetworkPolicy_to_Koki_NetworkPolicy(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert NetworkPolicy %s: %v", obj.Name, err)
+		}
+		if err := d.AddNetworkPolicy(types.NewNetworkPolicy(koki)); err != nil {
+			return fmt.Errorf("unable to add NetworkPolicy %s: %v", obj.Name, err)
+		}
+	case "HorizontalPodAutoscaler":
+		var obj autoscalingv1.HorizontalPodAutoscaler
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal HorizontalPodAutoscaler: %v", err)
+		}
+		koki, err := converters.Convert_Kube_autoscaling_v1_HorizontalPodAutoscaler_to_Koki_HorizontalPodAutoscaler(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert HorizontalPodAutoscaler %s: %v", obj.Name, err)
+		}
+		if err := d.AddHorizontalPodAutoscaler(types.NewHorizontalPodAutoscaler(koki)); err != nil {
+			return fmt.Errorf("unable to add HorizontalPodAutoscaler %s: %v", obj.Name, err)
+		}
+	case "PodDisruptionBudget":
+		var obj policyv1beta1.PodDisruptionBudget
+		if err := yaml.Unmarshal(raw, &obj); err != nil {
+			return fmt.Errorf("unable to unmarshal PodDisruptionBudget: %v", err)
+		}
+		koki, err := converters.Convert_Kube_policy_v1beta1_PodDisruptionBudget_to_Koki_PodDisruptionBudget(&obj)
+		if err != nil {
+			return fmt.Errorf("unable to convert PodDisruptionBudget %s: %v", obj.Name, err)
+		}
+		if err := d.AddPodDisruptionBudget(types.NewPodDisruptionBudget(koki)); err != nil {
+			return fmt.Errorf("unable to add PodDisruptionBudget %s: %v", obj.Name, err)
+		}
+	case "":
+		return fmt.Errorf("manifest document is missing a kind")
+	default:
+		return fmt.Errorf("unsupported manifest kind %q", meta.Kind)
+	}
+
+	return nil
+}
+
+// helmFuncMap provides the subset of Helm's built-in template functions
+// that RenderChart makes available to chart templates: include (render another
+// named template into this one), toYaml (marshal a value back to YAML, for
+// embedding values.yaml data into manifest fields) and tpl (render a string
+// as a template against the current values, for charts that template
+// strings that are themselves templates).
+func helmFuncMap(tmpl *template.Template) template.FuncMap {
+	return template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+		"toYaml": func(v interface{}) (string, error) {
+			out, err := yaml.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(string(out), "\n"), nil
+		},
+		"tpl": func(text string, data interface{}) (string, error) {
+			t, err := template.New("tpl").Funcs(helmFuncMap(tmpl)).Parse(text)
+			if err != nil {
+				return "", err
+			}
+			var buf bytes.Buffer
+			if err := t.Execute(&buf, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+	}
+}
+
+// RenderChart renders every *.yaml/*.yml file under templatesDir as a Go
+// text/template against the values decoded from valuesFile, in the minimal
+// Helm-compatible style: templates see their values under .Values, and can
+// call include/toYaml/tpl the way upstream charts do. The rendered
+// documents are concatenated with "---" separators into a single stream
+// suitable for LoadManifests. This is not a full Helm implementation - no
+// subcharts, no built-in objects like .Release or .Chart - just enough to
+// vendor a simple upstream chart's templates without hand-porting them to
+// types.*.
+func RenderChart(templatesDir, valuesFile string) (io.Reader, error) {
+	valuesRaw, err := ioutil.ReadFile(valuesFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read values file %s: %v", valuesFile, err)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(valuesRaw, &values); err != nil {
+		return nil, fmt.Errorf("unable to parse values file %s: %v", valuesFile, err)
+	}
+	data := map[string]interface{}{"Values": values}
+
+	tmpl := template.New("chart")
+	tmpl.Funcs(helmFuncMap(tmpl))
+
+	matches, err := filepath.Glob(filepath.Join(templatesDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list templates in %s: %v", templatesDir, err)
+	}
+	ymlMatches, err := filepath.Glob(filepath.Join(templatesDir, "*.yml"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list templates in %s: %v", templatesDir, err)
+	}
+	matches = append(matches, ymlMatches...)
+
+	for _, path := range matches {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read template %s: %v", path, err)
+		}
+		if _, err := tmpl.New(filepath.Base(path)).Parse(string(raw)); err != nil {
+			return nil, fmt.Errorf("unable to parse template %s: %v", path, err)
+		}
+	}
+
+	var rendered bytes.Buffer
+	for _, path := range matches {
+		rendered.WriteString("---\n")
+		if err := tmpl.ExecuteTemplate(&rendered, filepath.Base(path), data); err != nil {
+			return nil, fmt.Errorf("unable to render template %s: %v", path, err)
+		}
+		rendered.WriteString("\n")
+	}
+
+	return &rendered, nil
+}
+
+// LoadChart renders the chart rooted at chartDir (expecting a values.yaml
+// and a templates/ subdirectory, mirroring Helm's layout) and loads the
+// result via LoadManifests, so a vendored upstream chart can be deployed
+// without hand-porting it to types.*.
+func (d *Deployer) LoadChart(chartDir string) error {
+	rendered, err := RenderChart(filepath.Join(chartDir, "templates"), filepath.Join(chartDir, "values.yaml"))
+	if err != nil {
+		return fmt.Errorf("unable to render chart %s: %v", chartDir, err)
+	}
+	return d.LoadManifests(rendered)
+}